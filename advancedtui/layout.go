@@ -0,0 +1,272 @@
+package advancedtui
+
+import (
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// A part of the screen showing an editor
+type Window struct {
+	Editor   *core.Editor
+	Provider SyntaxProvider
+	Title    string
+	// As the editor has a single set of cursors, the ones of the window are
+	// kept here while it is not focused, with a backup of the contents in
+	// Version, so they can follow the changes done in other windows
+	Cursors  []*core.Cursor
+	Version  core.Version
+	// The window options set with :set, see Number, ScrollOff and Wrap for
+	// the ones used when rendering
+	Options   core.OptionValues
+	Number    bool // Whether line numbers are shown
+	ScrollOff int  // Rows kept visible above and below the main cursor
+	Wrap      bool // Whether long lines continue in the next rows
+	scroll    int
+}
+
+// A tree of windows, where every leaf has a window, and the rest split their
+// area between their children
+type Layout struct {
+	Window   *Window
+	Vertical bool // Whether the children are side by side
+	Children []*Layout
+	Parent   *Layout
+	Weight   float64 // Size relative to the siblings
+	area     area    // Where it was last rendered
+}
+
+type area struct {
+	top, left, rows, cols int
+}
+
+func newLayout(window *Window) *Layout {
+	return &Layout{Window: window, Weight: 1}
+}
+
+// Calls visit with the area of every window
+func (l *Layout) place(a area, visit func(*Layout, area)) {
+	l.area = a
+	if l.Window != nil {
+		visit(l, a)
+		return
+	}
+	total := 0.0
+	for _, child := range l.Children {
+		total += child.Weight
+	}
+	size := a.rows
+	if l.Vertical {
+		size = a.cols
+	}
+	start := 0
+	accumulated := 0.0
+	for i, child := range l.Children {
+		accumulated += child.Weight
+		end := int(float64(size) * accumulated / total + 0.5)
+		if i == len(l.Children) - 1 {
+			end = size
+		}
+		if l.Vertical {
+			child.place(area{a.top, a.left + start, a.rows, end - start}, visit)
+		} else {
+			child.place(area{a.top + start, a.left, end - start, a.cols}, visit)
+		}
+		start = end
+	}
+}
+
+func (l *Layout) leaves() []*Layout {
+	if l.Window != nil {
+		return []*Layout{l}
+	}
+	leaves := []*Layout{}
+	for _, child := range l.Children {
+		leaves = append(leaves, child.leaves()...)
+	}
+	return leaves
+}
+
+func (l *Layout) index() int {
+	for i, sibling := range l.Parent.Children {
+		if sibling == l {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Tui) Focused() *Window {
+	return t.focused.Window
+}
+
+func (t *Tui) Windows() []*Window {
+	windows := []*Window{}
+	for _, leaf := range t.root.leaves() {
+		windows = append(windows, leaf.Window)
+	}
+	return windows
+}
+
+func (t *Tui) Focus(window *Window) {
+	for _, leaf := range t.root.leaves() {
+		if leaf.Window == window {
+			t.focused = leaf
+		}
+	}
+}
+
+// Splits the focused window in two, focusing the new one
+func (t *Tui) SplitWindow(window *Window, vertical bool) {
+	focused := t.focused
+	window.scroll = focused.Window.scroll
+	parent := focused.Parent
+	if parent != nil && parent.Vertical == vertical {
+		leaf := newLayout(window)
+		leaf.Parent = parent
+		focused.Weight /= 2
+		leaf.Weight = focused.Weight
+		index := focused.index()
+		parent.Children = append(parent.Children[:index+1], append([]*Layout{leaf}, parent.Children[index+1:]...)...)
+		t.focused = leaf
+		return
+	}
+	// The focused leaf becomes a split with both windows
+	old := newLayout(focused.Window)
+	leaf := newLayout(window)
+	old.Parent = focused
+	leaf.Parent = focused
+	focused.Window = nil
+	focused.Vertical = vertical
+	focused.Children = []*Layout{old, leaf}
+	t.focused = leaf
+}
+
+// Closes the focused window, unless it is the only one
+func (t *Tui) CloseWindow() bool {
+	focused := t.focused
+	parent := focused.Parent
+	if parent == nil {
+		return false
+	}
+	index := focused.index()
+	parent.Children = append(parent.Children[:index], parent.Children[index+1:]...)
+	if index > 0 {
+		index--
+	}
+	neighbour := parent.Children[index]
+	neighbour.Weight += focused.Weight
+
+	if len(parent.Children) == 1 {
+		// The parent takes the place of its only child
+		parent.Window = neighbour.Window
+		parent.Vertical = neighbour.Vertical
+		parent.Children = neighbour.Children
+		for _, child := range parent.Children {
+			child.Parent = parent
+		}
+		neighbour = parent
+	}
+	t.focused = neighbour.leaves()[0]
+	return true
+}
+
+// Returns the closest window in the direction (e.g. 0, 1 for right) from the
+// focused one, or nil if there is none
+func (t *Tui) WindowInDirection(rows, cols int) *Window {
+	current := t.focused.area
+	var closest *Layout
+	closestDistance := 0
+	for _, leaf := range t.root.leaves() {
+		a := leaf.area
+		var distance int
+		var overlaps bool
+		switch {
+		case cols > 0:
+			distance = a.left - (current.left + current.cols)
+		case cols < 0:
+			distance = current.left - (a.left + a.cols)
+		case rows > 0:
+			distance = a.top - (current.top + current.rows)
+		case rows < 0:
+			distance = current.top - (a.top + a.rows)
+		}
+		if cols != 0 {
+			overlaps = a.top < current.top + current.rows && current.top < a.top + a.rows
+		} else {
+			overlaps = a.left < current.left + current.cols && current.left < a.left + a.cols
+		}
+		if leaf == t.focused || distance < 0 || !overlaps {
+			continue
+		}
+		if closest == nil || distance < closestDistance {
+			closest = leaf
+			closestDistance = distance
+		}
+	}
+	if closest == nil {
+		return nil
+	}
+	return closest.Window
+}
+
+// Returns the window the amount of steps after the focused one, wrapping
+func (t *Tui) NextWindow(steps int) *Window {
+	leaves := t.root.leaves()
+	index := 0
+	for i, leaf := range leaves {
+		if leaf == t.focused {
+			index = i
+		}
+	}
+	index = (index + steps) % len(leaves)
+	if index < 0 {
+		index += len(leaves)
+	}
+	return leaves[index].Window
+}
+
+// Grows the focused window by the amount of rows (or columns, if vertical),
+// taking them from a neighbour.
+func (t *Tui) ResizeWindow(amount int, vertical bool) {
+	child := t.focused
+	for child.Parent != nil && child.Parent.Vertical != vertical {
+		child = child.Parent
+	}
+	parent := child.Parent
+	if parent == nil {
+		return
+	}
+	// The weights are set to the sizes, so that the amount is exact
+	for _, sibling := range parent.Children {
+		if vertical {
+			sibling.Weight = float64(sibling.area.cols)
+		} else {
+			sibling.Weight = float64(sibling.area.rows)
+		}
+	}
+	index := child.index()
+	neighbour := index + 1
+	if neighbour == len(parent.Children) {
+		neighbour = index - 1
+	}
+	available := parent.Children[neighbour].Weight - 1
+	if float64(amount) > available {
+		amount = int(available)
+	}
+	if float64(-amount) > child.Weight - 1 {
+		amount = -int(child.Weight - 1)
+	}
+	child.Weight += float64(amount)
+	parent.Children[neighbour].Weight -= float64(amount)
+}
+
+// Gives all windows the same size
+func (t *Tui) EqualizeWindows() {
+	var equalize func(*Layout)
+	equalize = func(l *Layout) {
+		l.Weight = 1
+		for _, child := range l.Children {
+			equalize(child)
+		}
+	}
+	equalize(t.root)
+}
@@ -0,0 +1,128 @@
+package advancedtui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func layoutTestTui() *Tui {
+	root := newLayout(&Window{Title: "a"})
+	return &Tui{root: root, focused: root}
+}
+
+// Places the windows in a screen of the size, returning their areas by title
+func placeWindows(t *Tui, rows, cols int) map[string]area {
+	areas := map[string]area{}
+	t.root.place(area{0, 0, rows, cols}, func(leaf *Layout, a area) {
+		areas[leaf.Window.Title] = a
+	})
+	return areas
+}
+
+func TestSplitWindow(t *testing.T) {
+	tui := layoutTestTui()
+	tui.SplitWindow(&Window{Title: "b"}, true)
+	assert.Equal(t, "b", tui.Focused().Title)
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 40},
+		"b": {0, 40, 20, 40},
+	}, placeWindows(tui, 20, 80))
+
+	// In the same direction, the focused window is split in the same parent
+	tui.SplitWindow(&Window{Title: "c"}, true)
+	assert.Equal(t, 3, len(tui.root.Children))
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 40},
+		"b": {0, 40, 20, 20},
+		"c": {0, 60, 20, 20},
+	}, placeWindows(tui, 20, 80))
+
+	// In the other, it becomes a new split
+	tui.SplitWindow(&Window{Title: "d"}, false)
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 40},
+		"b": {0, 40, 20, 20},
+		"c": {0, 60, 10, 20},
+		"d": {10, 60, 10, 20},
+	}, placeWindows(tui, 20, 80))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, titles(tui.Windows()))
+}
+
+func TestCloseWindow(t *testing.T) {
+	tui := layoutTestTui()
+	assert.False(t, tui.CloseWindow())
+
+	tui.SplitWindow(&Window{Title: "b"}, true)
+	tui.SplitWindow(&Window{Title: "c"}, false)
+	assert.True(t, tui.CloseWindow())
+	// The split with a single window is replaced by it
+	assert.Equal(t, "b", tui.Focused().Title)
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 40},
+		"b": {0, 40, 20, 40},
+	}, placeWindows(tui, 20, 80))
+
+	assert.True(t, tui.CloseWindow())
+	assert.Equal(t, "a", tui.Focused().Title)
+	assert.Equal(t, tui.root, tui.focused)
+	assert.Equal(t, map[string]area{"a": {0, 0, 20, 80}}, placeWindows(tui, 20, 80))
+}
+
+func TestWindowInDirection(t *testing.T) {
+	tui := layoutTestTui()
+	tui.SplitWindow(&Window{Title: "b"}, true)
+	tui.SplitWindow(&Window{Title: "c"}, false)
+	placeWindows(tui, 20, 80)
+
+	assert.Equal(t, "a", tui.WindowInDirection(0, -1).Title)
+	assert.Equal(t, "b", tui.WindowInDirection(-1, 0).Title)
+	assert.Nil(t, tui.WindowInDirection(0, 1))
+	assert.Nil(t, tui.WindowInDirection(1, 0))
+
+	tui.Focus(tui.WindowInDirection(0, -1))
+	assert.Equal(t, "a", tui.Focused().Title)
+	assert.Equal(t, "b", tui.WindowInDirection(0, 1).Title)
+
+	assert.Equal(t, "c", tui.NextWindow(-1).Title)
+	assert.Equal(t, "b", tui.NextWindow(1).Title)
+	assert.Equal(t, "a", tui.NextWindow(3).Title)
+}
+
+func TestResizeWindow(t *testing.T) {
+	tui := layoutTestTui()
+	tui.SplitWindow(&Window{Title: "b"}, true)
+	tui.SplitWindow(&Window{Title: "c"}, false)
+	placeWindows(tui, 20, 80)
+
+	// The closest split in the direction is resized
+	tui.ResizeWindow(5, true)
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 35},
+		"b": {0, 35, 10, 45},
+		"c": {10, 35, 10, 45},
+	}, placeWindows(tui, 20, 80))
+
+	// The neighbour keeps at least a row
+	tui.ResizeWindow(100, false)
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 35},
+		"b": {0, 35, 1, 45},
+		"c": {1, 35, 19, 45},
+	}, placeWindows(tui, 20, 80))
+
+	tui.EqualizeWindows()
+	assert.Equal(t, map[string]area{
+		"a": {0, 0, 20, 40},
+		"b": {0, 40, 10, 40},
+		"c": {10, 40, 10, 40},
+	}, placeWindows(tui, 20, 80))
+}
+
+func titles(windows []*Window) []string {
+	result := []string{}
+	for _, window := range windows {
+		result = append(result, window.Title)
+	}
+	return result
+}
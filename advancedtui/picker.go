@@ -0,0 +1,147 @@
+package advancedtui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hhhhhhhhhn/hexes"
+	"github.com/hhhhhhhhhn/wr/core"
+	rw "github.com/mattn/go-runewidth"
+)
+
+// A list to choose from, drawn over the bottom of the editor. The items are
+// the ones matching the query, and the selected one can have a preview.
+type Picker struct {
+	Title      string
+	Query      []rune
+	Cursor     int          // Within the query
+	Items      []PickerItem // From the best match
+	Total      int          // Amount of items before filtering
+	Selected   int
+	Preview    *Window      // Or nil
+	PreviewRow int          // Shown in the middle of the preview
+	scroll     int
+}
+
+type PickerItem struct {
+	Text    string
+	Matched []int // Indices of the runes matching the query
+}
+
+var attrPickerMatch         = hexes.Join(hexes.NORMAL, hexes.BOLD, hexes.MAGENTA)
+var attrPickerSelected      = hexes.Join(hexes.NORMAL, hexes.REVERSE)
+var attrPickerSelectedMatch = hexes.Join(hexes.NORMAL, hexes.BOLD, hexes.MAGENTA, hexes.REVERSE)
+
+// Renders the editor with the picker over its bottom two thirds, and the
+// query in the place of the status bar
+func (t *Tui) RenderPicker(e *core.Editor, p *Picker) {
+	t.drawEditor(e)
+
+	rows, cols := t.renderer.Rows, t.renderer.Cols
+	listRows := (rows - 1) * 2 / 3 - 1
+	if listRows < 1 {
+		listRows = 1
+	}
+	top := rows - 1 - listRows
+	if top > 0 {
+		title := fmt.Sprintf(" %v %v/%v", p.Title, len(p.Items), p.Total)
+		t.renderer.SetAttribute(attrStatus)
+		t.renderer.SetString(top - 1, 0, padWithSpaces(title, rw.StringWidth(title), cols))
+	}
+	t.renderer.SetAttribute(attrDefault)
+	for row := top; row < top + listRows; row++ {
+		t.renderer.SetString(row, 0, strings.Repeat(" ", cols))
+	}
+
+	list := area{top, 0, listRows, cols}
+	if p.Preview != nil {
+		list.cols = cols / 2
+		t.renderer.SetAttribute(attrStatus)
+		for row := top; row < top + listRows; row++ {
+			t.renderer.Set(row, list.cols, ' ')
+		}
+		t.renderPreview(p.Preview, p.PreviewRow, area{top, list.cols + 1, listRows, cols - list.cols - 1})
+	}
+
+	if p.Selected < p.scroll {
+		p.scroll = p.Selected
+	}
+	if p.Selected >= p.scroll + listRows {
+		p.scroll = p.Selected - listRows + 1
+	}
+	for i := p.scroll; i < p.scroll + listRows && i < len(p.Items); i++ {
+		t.printPickerItem(p.Items[i], i == p.Selected, top + i - p.scroll, list)
+	}
+
+	t.printQuery(p.Query, p.Cursor, rows - 1, cols)
+	t.out.Flush()
+}
+
+func (t *Tui) printPickerItem(item PickerItem, selected bool, row int, a area) {
+	attribute, matchAttribute := attrDefault, attrPickerMatch
+	if selected {
+		attribute, matchAttribute = attrPickerSelected, attrPickerSelectedMatch
+	}
+	t.renderer.SetAttribute(attribute)
+	t.renderer.SetString(row, a.left, strings.Repeat(" ", a.cols))
+
+	matched := item.Matched
+	col := 1
+	for i, chr := range []rune(item.Text) {
+		width := rw.RuneWidth(chr)
+		if width < 1 {
+			chr, width = '?', 1
+		}
+		if col + width > a.cols - 1 {
+			break
+		}
+		if len(matched) > 0 && matched[0] == i {
+			t.renderer.SetAttribute(matchAttribute)
+			matched = matched[1:]
+		} else {
+			t.renderer.SetAttribute(attribute)
+		}
+		t.renderer.SetString(row, a.left + col, string(chr))
+		col += width
+	}
+	t.renderer.SetAttribute(attrDefault)
+}
+
+// Renders the lines around the row, without scrolling past the end
+func (t *Tui) renderPreview(w *Window, row int, a area) {
+	e := w.Editor
+	lineAmount := e.Buffer.GetLength()
+	scroll := row - a.rows / 2
+	if scroll > lineAmount - a.rows {
+		scroll = lineAmount - a.rows
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+
+	w.Provider.BeforeRender()
+	highlights := w.Provider.GetHighlights(scroll, scroll + a.rows)
+	for line := scroll; line < scroll + a.rows && line < lineAmount; line++ {
+		printLine(e, t, highlights[line - scroll], line, area{a.top + line - scroll, a.left, 1, a.cols}, false)
+	}
+}
+
+func (t *Tui) printQuery(query []rune, cursor int, row int, cols int) {
+	before := "> " + string(query[:cursor])
+	under := " "
+	after := ""
+	if cursor < len(query) {
+		under = string(query[cursor])
+		after = string(query[cursor+1:])
+	}
+	col := rw.StringWidth(before)
+
+	t.renderer.SetAttribute(attrStatus)
+	t.renderer.SetString(row, 0, strings.Repeat(" ", cols))
+	t.renderer.SetString(row, 0, before)
+	t.renderer.SetAttribute(attrActive)
+	t.renderer.SetString(row, col, under)
+	t.renderer.SetAttribute(attrStatus)
+	t.renderer.SetString(row, col + rw.StringWidth(under), after)
+	t.renderer.SetAttribute(attrDefault)
+}
@@ -3,7 +3,9 @@ package advancedtui
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -17,11 +19,12 @@ type Renderer = tui.Renderer
 type Tui struct {
 	out          *bufio.Writer
 	renderer     *hexes.Renderer
-	scroll       int
+	root         *Layout
+	focused      *Layout
 	statusText   string
 	statusOk     bool
 	getAttribute func(string) hexes.Attribute
-	provider     SyntaxProvider
+	headless     bool // The terminal was not set up (see NewHeadlessTui)
 }
 
 func NewTui() *Tui {
@@ -30,18 +33,60 @@ func NewTui() *Tui {
 	renderer := hexes.New(in, out)
 	renderer.Start()
 
+	root := newLayout(&Window{Provider: &NoHighlight{}, Wrap: true})
 	return &Tui {
 		renderer: renderer,
 		out: out,
-		scroll: 0,
+		root: root,
+		focused: root,
 		statusText: "",
 		statusOk: true,
-		provider: &NoHighlight{},
 	}
 }
 
+// Creates a Tui that draws to out as if it was a terminal of the size,
+// without setting up the terminal, for when there is none
+func NewHeadlessTui(out io.Writer, rows, cols int) *Tui {
+	renderer := hexes.New(nil, out)
+	renderer.Rows, renderer.Cols = rows, cols
+	for i := 0; i < rows; i++ {
+		attributes := make([]hexes.Attribute, cols)
+		for j := range attributes {
+			attributes[j] = renderer.DefaultAttribute
+		}
+		renderer.Lines = append(renderer.Lines, []rune(strings.Repeat(" ", cols)))
+		renderer.Attributes = append(renderer.Attributes, attributes)
+	}
+	renderer.CurrentAttribute = renderer.DefaultAttribute
+
+	root := newLayout(&Window{Provider: &NoHighlight{}, Wrap: true})
+	return &Tui {
+		renderer: renderer,
+		out: bufio.NewWriter(out),
+		root: root,
+		focused: root,
+		statusOk: true,
+		headless: true,
+	}
+}
+
+// Returns the text of each row of the screen
+func (t *Tui) Screen() []string {
+	rows := []string{}
+	for _, line := range t.renderer.Lines {
+		rows = append(rows, string(line))
+	}
+	return rows
+}
+
+// Returns the text of the status bar, and whether it shows an error
+func (t *Tui) Status() (string, bool) {
+	return t.statusText, t.statusOk
+}
+
+// Sets the provider of the focused window
 func (t *Tui) SetSyntaxProvider(provider SyntaxProvider) {
-	t.provider = provider
+	t.focused.Window.Provider = provider
 }
 
 func (t *Tui) fillBlank() {
@@ -51,26 +96,89 @@ func (t *Tui) fillBlank() {
 	}
 }
 
+// Renders all windows, with the focused one showing e
 func (t *Tui) RenderEditor(e *core.Editor) {
+	t.drawEditor(e)
+	t.out.Flush()
+}
+
+func (t *Tui) drawEditor(e *core.Editor) {
 	t.fillBlank()
+	t.focused.Window.Editor = e
 
-	renderRows := t.renderer.Rows - 1 // Extra row for commands
-	t.scroll = handleScroll(e, renderRows, t.scroll)
+	screen := area{0, 0, t.renderer.Rows - 1, t.renderer.Cols} // Extra row for commands
+	t.root.place(screen, func(leaf *Layout, a area) {
+		t.renderWindow(leaf.Window, a, leaf == t.focused)
+	})
 
-	lineAmount := e.Buffer.GetLength()
-	t.provider.BeforeRender()
-	highlights := t.provider.GetHighlights(t.scroll, t.scroll + renderRows)
-	for row := t.scroll; row < t.scroll + renderRows && row < lineAmount; row++ {
-		printLine(e, t, highlights[row - t.scroll], row, t.scroll)
+	printStatusBar(e, t.renderer, t.statusText, t.statusOk)
+}
+
+func (t *Tui) renderWindow(w *Window, a area, focused bool) {
+	e := w.Editor
+	if !focused {
+		view := *w.Editor
+		view.Cursors = w.Cursors
+		e = &view
+	}
+	// Separators are drawn on the right and bottom edges if there are
+	// windows after them
+	if a.left + a.cols < t.renderer.Cols {
+		a.cols--
+		t.renderer.SetAttribute(attrStatus)
+		for row := a.top; row < a.top + a.rows; row++ {
+			t.renderer.Set(row, a.left + a.cols, ' ')
+		}
+	}
+	if t.root.Window == nil {
+		a.rows--
+		if focused {
+			t.renderer.SetAttribute(attrActive)
+		} else {
+			t.renderer.SetAttribute(attrStatus)
+		}
+		t.renderer.SetString(a.top + a.rows, a.left, padWithSpaces(" " + w.Title, len(w.Title) + 1, a.cols)[:a.cols])
 	}
 
-	printStatusBar(e, t.renderer, t.statusText, t.statusOk)
+	lineAmount := e.Buffer.GetLength()
+	numberWidth := 0
+	if w.Number {
+		numberWidth = len(strconv.Itoa(lineAmount)) + 1
+		if numberWidth >= a.cols {
+			numberWidth = 0
+		}
+	}
+	numberLeft := a.left
+	a.left += numberWidth
+	a.cols -= numberWidth
+	height := func(row int) int { return 1 }
+	if w.Wrap {
+		height = func(row int) int {
+			if row >= lineAmount {
+				return 1
+			}
+			return lineHeight(e, e.Buffer.GetLine(row), a.cols)
+		}
+	}
+	w.scroll = handleScroll(e, a.rows, w.scroll, w.ScrollOff, height)
 
-	t.out.Flush()
+	w.Provider.BeforeRender()
+	highlights := w.Provider.GetHighlights(w.scroll, w.scroll + a.rows)
+	screenRow := 0
+	for row := w.scroll; screenRow < a.rows && row < lineAmount; row++ {
+		if numberWidth > 0 {
+			t.renderer.SetAttribute(attrLineNumber)
+			t.renderer.SetString(a.top + screenRow, numberLeft, fmt.Sprintf("%*d ", numberWidth - 1, row + 1))
+		}
+		lineArea := area{a.top + screenRow, a.left, a.rows - screenRow, a.cols}
+		screenRow += printLine(e, t, highlights[row - w.scroll], row, lineArea, w.Wrap)
+	}
 }
 
 func (t *Tui) End() {
-	t.renderer.End()
+	if !t.headless {
+		t.renderer.End()
+	}
 	t.out.Flush()
 }
 
@@ -79,19 +187,37 @@ var attrStatusErr = hexes.Join(hexes.NORMAL, hexes.BOLD, hexes.BG_RED, hexes.REV
 var attrCursor    = hexes.Join(hexes.NORMAL, hexes.REVERSE)
 var attrActive    = hexes.Join(hexes.NORMAL, hexes.MAGENTA, hexes.REVERSE)
 var attrStatus    = hexes.Join(hexes.NORMAL, hexes.REVERSE)
+var attrLineNumber = hexes.Join(hexes.NORMAL, hexes.YELLOW)
 
-func handleScroll(e*core.Editor, renderRows int, currentScroll int) (newScroll int) {
+// Scrolls so the main cursor is visible, with the margin around it if there
+// is space. The height is the amount of screen rows a row of the buffer takes.
+func handleScroll(e*core.Editor, renderRows int, currentScroll int, margin int, height func(row int) int) (newScroll int) {
 	var lastCursorRow int
 	if len(e.Cursors) > 0 {
 		lastCursorRow = e.Cursors[len(e.Cursors) - 1].Start.Row
 	} else {
 		lastCursorRow = 0
 	}
-	if lastCursorRow < currentScroll {
-		currentScroll = lastCursorRow
+	if margin > (renderRows - 1) / 2 {
+		margin = (renderRows - 1) / 2
+	}
+	if lastCursorRow - margin < currentScroll {
+		currentScroll = lastCursorRow - margin
+	}
+	// The first row from which the rows until the margin below fit
+	first, used := lastCursorRow + margin + 1, 0
+	for first > 0 && used + height(first - 1) <= renderRows {
+		first--
+		used += height(first)
 	}
-	if lastCursorRow > currentScroll + renderRows - 1 {
-		currentScroll = lastCursorRow - renderRows + 1
+	if first > lastCursorRow {
+		first = lastCursorRow
+	}
+	if currentScroll < first {
+		currentScroll = first
+	}
+	if currentScroll < 0 {
+		currentScroll = 0
 	}
 	return currentScroll
 }
@@ -106,23 +232,43 @@ func getLineAsString(e *core.Editor, row int) string {
 	return strings.ReplaceAll(string(e.Buffer.GetLine(row)), "\t", strings.Repeat(" ", e.Config.Tabsize))
 }
 
-func printLine(e *core.Editor, tui *Tui, highlights []Highlight, row, scroll int) {
+// Draws the line from the top of the area. If wrap is true, what doesn't fit
+// continues in the next rows of the area, else it is cut. Returns the amount
+// of rows used.
+func printLine(e *core.Editor, tui *Tui, highlights []Highlight, row int, a area, wrap bool) (rows int) {
 	line := e.Buffer.GetLine(row)
 	originalLineCols  := core.ColumnSpan(e, line)
-	if originalLineCols < tui.renderer.Cols {
-		line = append(line, []rune(strings.Repeat(" ", tui.renderer.Cols - originalLineCols))...)
+	rows = 1
+	if wrap {
+		rows = lineHeight(e, line, a.cols)
+		if rows > a.rows {
+			rows = a.rows
+		}
+	}
+	// Padded so the cursors after the end are shown
+	if originalLineCols < rows * a.cols {
+		line = append(line, []rune(strings.Repeat(" ", rows * a.cols - originalLineCols))...)
 	}
 
-	col := 0
+	screenRow := 0
+	col := 0 // In the screen row
+	lineCol := 0
 	byt := 0
 	for _, chr := range line {
+		if col + core.RuneWidth(e, chr) > a.cols {
+			if col == 0 || screenRow + 1 >= rows {
+				break
+			}
+			screenRow++
+			col = 0
+		}
 		// Advances the captures
 		for len(highlights) > 1 && byt >= highlights[1].Byte {
 			highlights = highlights[1:]
 		}
 
-		withinCursor, withinLast, cursor := isWithinCursor(e, row, col)
-		if withinCursor && (col <= originalLineCols || (cursor.Start.Row == row && cursor.Start.Column > originalLineCols)) {
+		withinCursor, withinLast, cursor := isWithinCursor(e, row, lineCol)
+		if withinCursor && (lineCol <= originalLineCols || (cursor.Start.Row == row && cursor.Start.Column > originalLineCols)) {
 			if withinLast {
 				tui.renderer.SetAttribute(attrActive)
 			} else {
@@ -132,15 +278,38 @@ func printLine(e *core.Editor, tui *Tui, highlights []Highlight, row, scroll int
 			tui.renderer.SetAttribute(highlights[0].Attribute)
 		}
 		if chr == '\t' {
-			tui.renderer.SetString(row - scroll, col, strings.Repeat(" ", e.Config.Tabsize))
+			tui.renderer.SetString(a.top + screenRow, a.left + col, strings.Repeat(" ", e.Config.Tabsize))
 		} else {
-			tui.renderer.SetString(row - scroll, col, string(chr))
+			tui.renderer.SetString(a.top + screenRow, a.left + col, string(chr))
 		}
 		col += core.RuneWidth(e, chr)
+		lineCol += core.RuneWidth(e, chr)
 		byt += utf8.RuneLen(chr)
 	}
 
 	tui.renderer.SetAttribute(attrDefault)
+	return rows
+}
+
+// The rows the line takes when wrapped at the columns, counting the one after
+// its end, where the cursor can be
+func lineHeight(e *core.Editor, line []rune, cols int) int {
+	rows, col := 1, 0
+	for _, chr := range line {
+		width := core.RuneWidth(e, chr)
+		if col + width > cols {
+			if col == 0 {
+				break
+			}
+			rows++
+			col = 0
+		}
+		col += width
+	}
+	if col + 1 > cols {
+		rows++
+	}
+	return rows
 }
 
 func isWithinCursor(e *core.Editor, row, col int) (isWithin bool, isLast bool, cursor *core.Cursor) {
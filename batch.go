@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hhhhhhhhhn/wr/advancedtui"
+)
+
+// Whether the editor runs without a terminal, with -c or -s
+var batch bool
+
+// Runs the commands and then types the keys of the script file in the
+// files, without a terminal. The first command that fails, or a file with
+// unsaved changes at the end (like :qa) is an error. Otherwise, it quits.
+func runBatch(f flags) error {
+	batch = true
+	renderer = advancedtui.NewHeadlessTui(io.Discard, 24, 80)
+	// There are no keys before the script, so the commands that wait for
+	// them, like :s with the c flag, get the end of the input
+	readInput(&scriptInput{})
+	if f.config != "NONE" {
+		if err := runConfig(f.config); err != nil {
+			return err
+		}
+	}
+	for _, file := range f.files {
+		if err := openFile(file); err != nil {
+			return err
+		}
+	}
+	switchBuffer(0)
+
+	for _, command := range f.commands {
+		command = strings.TrimPrefix(command, ":")
+		inputEnd = nil
+		output, ok := runCommand(command)
+		if inputEnd != nil {
+			return fmt.Errorf("%v: %v", command, inputEnd)
+		} else if !ok {
+			return fmt.Errorf("%v: %v", command, output)
+		}
+	}
+	if f.script != "" {
+		source, err := scriptFile(f.script)
+		if err != nil {
+			return err
+		}
+		if err := runInput(source); err != errInputEnded {
+			return err
+		}
+	}
+	output, _ := quitAll(false)
+	return errors.New(output)
+}
+
+// Reads the keys of the file, or of stdin for "-". A newline at the end is
+// not typed.
+func scriptFile(filename string) (*scriptInput, error) {
+	var contents []byte
+	var err error
+	if filename == "-" {
+		contents, err = io.ReadAll(os.Stdin)
+	} else {
+		contents, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newScriptInput(strings.TrimSuffix(string(contents), "\n"))
+}
+
+// Runs the command and shows its output. In batch mode, a failed command
+// ends the input (see endInput).
+func runAndShow(command string) {
+	statusText, statusOk = runCommand(command)
+	renderer.ChangeStatus(statusText, statusOk)
+	if batch && !statusOk {
+		endInput(fmt.Errorf("%v: %v", command, statusText))
+	}
+}
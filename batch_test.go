@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchFailedCommand(t *testing.T) {
+	startSession(t, "one two\n")
+	batch = true
+	defer func() { batch = false }()
+	script, err := newScriptInput("dw:nosuch<CR>dw")
+	assert.Nil(t, err)
+	assert.Equal(t, `nosuch: command "nosuch" not found`, runInput(script).Error())
+	assert.Equal(t, "two", bufferText())
+	text, ok := renderer.Status()
+	assert.Equal(t, `command "nosuch" not found`, text)
+	assert.False(t, ok)
+}
+
+// Starts a session without files, for runBatch to open them
+func startBatch(t *testing.T) {
+	startSession(t, "")
+	openBuffers, currentBuffer, editor = nil, 0, nil
+}
+
+func TestBatchCommands(t *testing.T) {
+	startBatch(t)
+	defer func() { batch = false }()
+	filename := filepath.Join(t.TempDir(), "test.txt")
+	assert.Nil(t, os.WriteFile(filename, []byte("foo\n"), 0644))
+	err := runBatch(flags{files: []string{filename}, config: "NONE", commands: []string{":%s/o/0/g", "nosuch"}})
+	assert.Equal(t, `nosuch: command "nosuch" not found`, err.Error())
+	assert.Equal(t, "f00", bufferText())
+
+	startBatch(t)
+	script := filepath.Join(t.TempDir(), "script.keys")
+	assert.Nil(t, os.WriteFile(script, []byte("x:w<CR>:s/o/0/<CR>\n"), 0644))
+	err = runBatch(flags{files: []string{filename}, config: "NONE", script: script})
+	assert.Contains(t, err.Error(), "has unsaved changes")
+	contents, _ := os.ReadFile(filename)
+	assert.Equal(t, "oo\n", string(contents))
+}
+
+// The commands that wait for keys fail, as there are none
+func TestBatchCommandsWithoutKeys(t *testing.T) {
+	startBatch(t)
+	defer func() { batch = false }()
+	filename := filepath.Join(t.TempDir(), "test.txt")
+	assert.Nil(t, os.WriteFile(filename, []byte("foo\n"), 0644))
+	err := runBatch(flags{files: []string{filename}, config: "NONE", commands: []string{"s/o/0/gc", "w"}})
+	assert.Equal(t, "s/o/0/gc: the input ended", err.Error())
+	assert.Equal(t, "foo", bufferText())
+
+	startBatch(t)
+	err = runBatch(flags{files: []string{filename}, config: "NONE", commands: []string{"files"}})
+	assert.Equal(t, "files: the input ended", err.Error())
+}
+
+func TestBatchFailedCommandInNormal(t *testing.T) {
+	startSession(t, "one two three\n")
+	batch = true
+	defer func() { batch, inputEnd = false, nil }()
+	// The keys after the failed command are not done
+	runCommand("normal dw:nosuch\ndw")
+	assert.Equal(t, "two three", bufferText())
+	assert.Equal(t, `nosuch: command "nosuch" not found`, inputEnd.Error())
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hhhhhhhhhn/wr/advancedtui"
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/hhhhhhhhhn/wr/treesitter"
+)
+
+// A file being edited, with its own cursors, history and language
+type openBuffer struct {
+	filename       string
+	editor         *core.Editor
+	buffer         *treesitter.Buffer
+	language       string
+	syntaxProvider advancedtui.SyntaxProvider
+	job            *job // If it is the output of one
+	options        core.OptionValues
+}
+
+var openBuffers []*openBuffer
+var currentBuffer int
+
+// Returned by the commands that work on the current buffer when there is
+// none, like while running the config file
+var errNoBuffer = errors.New("no file is open")
+
+var editorConfig = core.EditorConfig{
+	Tabsize: 4,
+	MaxVersions: 1000,
+	MaxVersionBytes: 128 << 20,
+}
+
+// The last search, shared by all buffers
+var searchRegex = regexp.MustCompile(`^\s(?P<Cursor>)\S`)
+
+// The error is the one of loading the undo file, in which case the history
+// starts empty
+func newOpenBuffer(filename string) (*openBuffer, error) {
+	languageName := languageForFile(filename)
+	lang, _ := treesitter.GetLanguage(languageName)
+	buffer := treesitter.NewBuffer(*lang)
+	loadBuffer(filename, buffer)
+
+	editor := &core.Editor{
+		Buffer: buffer,
+		Config: editorConfig,
+	}
+	core.SetCursors(0, 0, 0, 1)(editor)
+	// A missing or outdated undo file is expected, as it is only written by
+	// wr, so only other errors are returned
+	var err error
+	loaded := false
+	if options.Bool(globalOptions, "undofile") {
+		err = core.LoadUndoFile(editor, filename)
+		loaded = err == nil
+	}
+	// A loaded history already has the save of the contents, so opening is
+	// only marked as one without it
+	if !loaded {
+		editor.MarkSaved()
+	}
+	if os.IsNotExist(err) || err == core.ErrUndoFileOutdated {
+		err = nil
+	} else if err != nil {
+		err = fmt.Errorf("could not load the undo file of %v: %v", filename, err)
+	}
+
+	return &openBuffer{
+		filename: filename,
+		editor: editor,
+		buffer: buffer,
+		language: languageName,
+		syntaxProvider: treesitter.NewSyntaxProvider(buffer, getAttribute),
+		options: newBufferOptions(editor),
+	}, err
+}
+
+// Returns the language of the file by its extension, or the default one
+func languageForFile(filename string) string {
+	if name := treesitter.LanguageNameForFile(filename); name != "" {
+		return name
+	}
+	return options.String(globalOptions, "defaultlanguage")
+}
+
+func current() *openBuffer {
+	return openBuffers[currentBuffer]
+}
+
+// Opens the file, or switches to it if it is already open. The error is the
+// one of loading its undo file or of the commands for its language (see
+// :onlanguage), and the file is opened anyway.
+func openFile(filename string) error {
+	for i, open := range openBuffers {
+		if sameFile(open.filename, filename) {
+			switchBuffer(i)
+			return nil
+		}
+	}
+	open, undoErr := newOpenBuffer(filename)
+	openBuffers = append(openBuffers, open)
+	switchBuffer(len(openBuffers) - 1)
+	err := runLanguageCommands(current().language)
+	if undoErr != nil && err != nil {
+		return fmt.Errorf("%v | %v", undoErr, err)
+	} else if undoErr != nil {
+		return undoErr
+	}
+	return err
+}
+
+// Loads the file again, as a single undo step. Only the lines that changed
+// are replaced, so the cursors in the rest stay in place.
+func reload(force bool) (string, bool) {
+	if len(openBuffers) == 0 {
+		return errNoBuffer.Error(), false
+	}
+	if err := checkChangeable(); err != nil {
+		return err.Error(), false
+	}
+	if !force && editor.Modified() {
+		return current().filename + " has unsaved changes (add ! to override)", false
+	}
+	lines, err := readLines(current().filename)
+	if err != nil {
+		return err.Error(), false
+	}
+	editor.MarkLabeledUndo("reload")
+	core.SetLines(lines)(editor)
+	editor.MarkSaved()
+	return "reloaded " + current().filename, true
+}
+
+func sameFile(a, b string) bool {
+	absoluteA, errA := filepath.Abs(a)
+	absoluteB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absoluteA == absoluteB
+}
+
+// Shows the buffer in the focused window
+func switchBuffer(index int) {
+	currentBuffer = index
+	editor = current().editor
+	buffer = current().buffer
+	syntaxProvider = current().syntaxProvider
+	renderer.SetSyntaxProvider(syntaxProvider)
+	renderer.Focused().Title = current().filename
+}
+
+// Switches to another buffer, wrapping around
+func cycleBuffer(steps int) {
+	index := (currentBuffer + steps) % len(openBuffers)
+	if index < 0 {
+		index += len(openBuffers)
+	}
+	switchBuffer(index)
+}
+
+// Quits if it was the last buffer. Other windows showing it change to the
+// new current buffer.
+func closeBuffer(index int) {
+	closed := openBuffers[index]
+	if closed.job != nil {
+		closed.job.cancel()
+	}
+	openBuffers = append(openBuffers[:index], openBuffers[index+1:]...)
+	if len(openBuffers) == 0 {
+		quit()
+	}
+	if index >= len(openBuffers) {
+		index = len(openBuffers) - 1
+	}
+	switchBuffer(index)
+	for _, window := range renderer.Windows() {
+		if window.Editor == closed.editor {
+			window.Editor = editor
+			window.Provider = syntaxProvider
+			window.Title = current().filename
+			// The contents kept were of the closed buffer, and the focused
+			// window uses the cursors of the editor
+			window.Version = 0
+			if window != renderer.Focused() {
+				keepCursors(window, editor.Cursors)
+			}
+		}
+	}
+}
+
+func modifiedBuffers() (modified []string) {
+	for _, open := range openBuffers {
+		if open.job == nil && open.editor.Modified() {
+			modified = append(modified, open.filename)
+		}
+	}
+	return modified
+}
+
+func listBuffers() string {
+	list := []string{}
+	for i, open := range openBuffers {
+		entry := fmt.Sprintf("%v %v", i + 1, open.filename)
+		if i == currentBuffer {
+			entry = "*" + entry
+		}
+		if open.editor.Modified() {
+			entry += " [+]"
+		}
+		list = append(list, entry)
+	}
+	return strings.Join(list, " | ")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenFileUndoErrors(t *testing.T) {
+	startSession(t, "")
+	filename := filepath.Join(t.TempDir(), "test.txt")
+	assert.Nil(t, os.WriteFile(filename, []byte("text\n"), 0644))
+	assert.Nil(t, os.WriteFile(core.UndoFilename(filename), []byte("not an undo file"), 0644))
+	err := openFile(filename)
+	assert.Contains(t, err.Error(), "could not load the undo file of " + filename)
+	// It is opened anyway
+	assert.Equal(t, filename, current().filename)
+	assert.Equal(t, "text", bufferText())
+
+	// A missing one is not an error
+	other := filepath.Join(t.TempDir(), "other.txt")
+	assert.Nil(t, openFile(other))
+}
+
+func TestReopenKeepsSaves(t *testing.T) {
+	startSession(t, "one\n")
+	filename := current().filename
+	typeKeys(t, "x:w<CR>x:w<CR>")
+	assert.Equal(t, 3, editor.History.Current.Save)
+
+	openBuffers, currentBuffer, editor = nil, 0, nil
+	assert.Nil(t, openFile(filename))
+	assert.False(t, editor.Modified())
+	// Opening is not a save
+	assert.Equal(t, 3, editor.History.Current.Save)
+	runCommand("earlier 1f")
+	assert.Equal(t, "ne", bufferText())
+	assert.True(t, editor.Modified())
+	runCommand("earlier 1f")
+	assert.Equal(t, "one", bufferText())
+	runCommand("later 2f")
+	assert.False(t, editor.Modified())
+}
+
+func TestHistoryLimitOptions(t *testing.T) {
+	startSession(t, "one\n")
+	defer runCommand("set maxversions& maxversionage&")
+	runCommand("set maxversions=2 maxversionage=60")
+	assert.Equal(t, 2, editor.Config.MaxVersions)
+	assert.Equal(t, time.Minute, editor.Config.MaxVersionAge)
+
+	typeKeys(t, "xxx")
+	assert.Equal(t, 2, len(editor.History.Revisions))
+	// New buffers have them too
+	assert.Nil(t, openFile(filepath.Join(t.TempDir(), "other.txt")))
+	assert.Equal(t, 2, openBuffers[1].editor.Config.MaxVersions)
+}
+
+func TestUndoFileOption(t *testing.T) {
+	startSession(t, "one\n")
+	filename := current().filename
+	runCommand("set noundofile")
+	output, ok := runCommand("w")
+	assert.True(t, ok)
+	assert.Equal(t, "saved " + filename, output)
+	_, err := os.Stat(core.UndoFilename(filename))
+	assert.True(t, os.IsNotExist(err))
+
+	runCommand("set undofile")
+	batch = true
+	runCommand("w")
+	batch = false
+	_, err = os.Stat(core.UndoFilename(filename))
+	assert.True(t, os.IsNotExist(err))
+
+	// Failing to save the history is not a failed write
+	assert.Nil(t, os.Mkdir(core.UndoFilename(filename), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(core.UndoFilename(filename), "file"), nil, 0644))
+	typeKeys(t, "x")
+	output, ok = runCommand("w")
+	assert.True(t, ok)
+	assert.Contains(t, output, "saved " + filename + ", but not its undo file")
+	contents, _ := os.ReadFile(filename)
+	assert.Equal(t, "ne\n", string(contents))
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/hhhhhhhhhn/hexes/input"
 	"github.com/hhhhhhhhhn/wr/core"
@@ -13,87 +17,535 @@ import (
 	"github.com/hhhhhhhhhn/wr/advancedtui"
 )
 
-func commandMode(command string) {
-	cursor := 0
+const maxHistory = 1000
+
+var commandHistory = core.NewCommandHistory(maxHistory)
+// Added in this session, to be merged with the saved history when quitting
+var sessionHistory []string
+
+// $XDG_STATE_HOME/wr/history, or ~/.local/state/wr/history
+func historyFilename() string {
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(state, "wr", "history")
+}
+
+func loadHistory() error {
+	var err error
+	commandHistory, err = core.LoadCommandHistory(historyFilename(), maxHistory)
+	return err
+}
+
+// Other sessions may have saved their history since this one started, so
+// the commands of this one are added to it
+func saveHistory() error {
+	if len(sessionHistory) == 0 {
+		return nil
+	}
+	saved, err := core.LoadCommandHistory(historyFilename(), maxHistory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range sessionHistory {
+		saved.Add(entry)
+	}
+	return saved.Save(historyFilename())
+}
+
+// The command being typed in command mode, changed by its keys
+type commandLine struct {
+	text   []rune
+	cursor int
+	// Up and down go through the history entries starting with what was
+	// typed
+	typed        string
+	historyIndex int
+	// Pressing tab again cycles through the completions
+	completions                      []string
+	completionIndex, completionStart int
+	// Set by the keys that keep going through the history or the completions
+	keepHistory, keepCompletions bool
+	done                         bool
+}
+
+// The other keys are typed
+var commandKeys *core.Keymap[func(*commandLine)]
+
+func init() {
+	commandKeys = keymapOf(map[string]func(*commandLine){
+		"<Up>": func(line *commandLine) { line.browseHistory(-1) },
+		"<Down>": func(line *commandLine) { line.browseHistory(1) },
+		"<Left>": func(line *commandLine) {
+			if line.cursor > 0 {
+				line.cursor--
+			}
+		},
+		"<Right>": func(line *commandLine) {
+			if line.cursor < len(line.text) {
+				line.cursor++
+			}
+		},
+		// hexes only reads the rxvt home and end keys, so the readline ones
+		// work too
+		"<Home>": func(line *commandLine) { line.cursor = 0 },
+		"<C-a>": func(line *commandLine) { line.cursor = 0 },
+		"<End>": func(line *commandLine) { line.cursor = len(line.text) },
+		"<C-e>": func(line *commandLine) { line.cursor = len(line.text) },
+		"<CR>": func(line *commandLine) {
+			commandHistory.Add(string(line.text))
+			sessionHistory = append(sessionHistory, string(line.text))
+			line.done = true
+			runAndShow(string(line.text))
+		},
+		"<Esc>": func(line *commandLine) { line.done = true },
+		"<BS>": func(line *commandLine) {
+			if line.cursor > 0 {
+				line.text = append(line.text[:line.cursor-1], line.text[line.cursor:]...)
+				line.cursor--
+			}
+		},
+		"<C-w>": func(line *commandLine) { line.text, line.cursor = core.DeleteWordBefore(line.text, line.cursor) },
+		"<C-u>": func(line *commandLine) {
+			line.text = line.text[line.cursor:]
+			line.cursor = 0
+		},
+		"<Tab>": (*commandLine).complete,
+	})
+}
+
+func commandMode(initial string) {
+	line := &commandLine{text: []rune(initial), typed: initial, historyIndex: len(commandHistory.Entries)}
+	line.cursor = len(line.text)
+
+	previousRedraw := redraw
+	redraw = func() {
+		render()
+		renderer.RenderCommand(string(line.text), len(string(line.text[:line.cursor])))
+	}
+	defer func() { redraw = previousRedraw }()
+
+	for !line.done {
+		if len(playback) == 0 {
+			renderer.RenderCommand(string(line.text), len(string(line.text[:line.cursor])))
+		}
+		action, read := readKeys("command", commandKeys, 1)
+		switch read {
+		case mapped:
+			continue
+		case bound:
+			action(line)
+		default:
+			event := getEvent()
+			if event.EventType != input.KeyPressed {
+				continue
+			}
+			if unicode.IsPrint(event.Chr) {
+				line.text = append(line.text[:line.cursor], append([]rune{event.Chr}, line.text[line.cursor:]...)...)
+				line.cursor++
+			}
+		}
+		if !line.keepCompletions {
+			line.completions = nil
+		}
+		if !line.keepHistory {
+			line.typed = string(line.text)
+			line.historyIndex = len(commandHistory.Entries)
+		}
+		line.keepHistory, line.keepCompletions = false, false
+	}
+}
+
+func (line *commandLine) browseHistory(direction int) {
+	index := commandHistory.Find(line.typed, line.historyIndex, direction)
+	if index >= 0 {
+		line.historyIndex = index
+		if index < len(commandHistory.Entries) {
+			line.text = []rune(commandHistory.Entries[index])
+		} else {
+			line.text = []rune(line.typed)
+		}
+		line.cursor = len(line.text)
+	}
+	// What was typed is kept while moving through the history
+	line.keepHistory = true
+}
+
+func (line *commandLine) complete() {
+	line.keepCompletions = true
+	if line.completions == nil {
+		start, candidates := completionCandidates(line.text[:line.cursor])
+		word := string(line.text[start:line.cursor])
+		matching := core.Complete(word, candidates)
+		common := core.CommonPrefix(matching)
+		if len(matching) == 0 {
+			return
+		}
+		// The shared part is completed first
+		if len(matching) == 1 || len(common) > len(word) {
+			line.text, line.cursor = replaceRunes(line.text, start, line.cursor, common)
+			return
+		}
+		line.completions, line.completionIndex, line.completionStart = matching, -1, start
+	}
+	line.completionIndex = (line.completionIndex + 1) % len(line.completions)
+	line.text, line.cursor = replaceRunes(line.text, line.completionStart, line.cursor, line.completions[line.completionIndex])
+}
+
+// Returns the text with the part from start to end replaced, and the end of
+// the replacement
+func replaceRunes(text []rune, start, end int, replacement string) ([]rune, int) {
+	result := append([]rune{}, text[:start]...)
+	result = append(result, []rune(replacement)...)
+	newEnd := len(result)
+	return append(result, text[end:]...), newEnd
+}
+
+// Returns where the word being completed starts in the command before the
+// cursor, and what it can be completed with
+func completionCandidates(before []rune) (start int, candidates []string) {
+	text := string(before)
+	_, rest, err := core.ParseRange(editor, text)
+	if err != nil {
+		rest = text
+	}
+	space := strings.Index(rest, " ")
+	if space < 0 {
+		return len(before) - len([]rune(rest)), commandNames()
+	}
+	argument := rest[space+1:]
+	start = len(before) - len([]rune(argument))
+	switch rest[:space] {
+	case "w", "e", "e!", "split", "vsplit", "cfile":
+		return start, core.CompletePath(argument)
+	case "language":
+		return start, treesitter.LanguageNames()
+	case "errorformat":
+		return start, errorFormatNames()
+	case "set", "setlocal":
+		// Each argument is an option
+		start = len(before) - len([]rune(argument[strings.LastIndex(argument, " ")+1:]))
+		return start, optionNames()
+	}
+	return len(before), nil
+}
+
+// Returns the names of all the commands and aliases, sorted
+func commandNames() []string {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	for name := range rangeCommands {
+		names = append(names, name)
+	}
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Writes the buffer, and its undo history unless the undofile option is off
+// or in batch mode. The error of saving the history is returned apart, as the
+// buffer is written anyway.
+func save(filename string) (undoErr error, err error) {
+	if current().job != nil {
+		return nil, errJobOutput
+	}
+	err = core.SaveToFile(editor, filename)
+	if err != nil || batch || !options.Bool(globalOptions, "undofile") {
+		return nil, err
+	}
+	return core.SaveUndoFile(editor, filename), nil
+}
+
+// Moves through the undo history like Vim's :earlier and :later. The
+// argument can be a count of changes ("3"), a time ("10s", "5m", "1h", "2d")
+// or a count of file writes ("1f").
+func travel(args []string, direction int) (string, bool) {
+	if err := checkChangeable(); err != nil {
+		return err.Error(), false
+	}
+	if len(args) == 1 {
+		editor.UndoChronological(direction)
+		return "", true
+	}
+	arg := args[1]
+	// At most one unit, after the digits
+	number, unit := arg, byte(0)
+	if last := arg[len(arg)-1]; strings.IndexByte("smhdf", last) >= 0 {
+		number, unit = arg[:len(arg)-1], last
+	}
+	count, err := strconv.Atoi(number)
+	if err != nil || strings.Trim(number, "0123456789") != "" {
+		return "invalid count: " + arg, false
+	}
+	count *= direction
+	switch unit {
+	case 'f':
+		editor.UndoSaves(count)
+	case 's':
+		editor.UndoTime(time.Duration(count) * time.Second)
+	case 'm':
+		editor.UndoTime(time.Duration(count) * time.Minute)
+	case 'h':
+		editor.UndoTime(time.Duration(count) * time.Hour)
+	case 'd':
+		editor.UndoTime(time.Duration(count) * 24 * time.Hour)
+	default:
+		editor.UndoChronological(count)
+	}
+	return "", true
+}
+
+// Replaces the matches as a single undo step. If the substitution has the
+// confirm flag, each match is shown, asking whether to replace it.
+func substitute(substitution *core.Substitution, matches []core.Match) (string, bool) {
+	if err := checkChangeable(); err != nil {
+		return err.Error(), false
+	}
+	if substitution.Confirm {
+		matches = confirmMatches(matches)
+	}
+	if len(matches) == 0 {
+		return "pattern not found: " + substitution.Regex.String(), false
+	}
+	editor.MarkLabeledUndo("substitute")
+	core.ReplaceMatches(matches)(editor)
+	return fmt.Sprintf("%v substitutions", len(matches)), true
+}
+
+// Asks for each match whether to replace it: y (yes), n (no), a (all the
+// remaining) or q (quit)
+func confirmMatches(matches []core.Match) []core.Match {
+	cursors := core.CopyCursors(editor.Cursors)
+	defer func() { editor.Cursors = cursors }()
+
+	accepted := []core.Match{}
+	for i, match := range matches {
+		shown := match.Range
+		if shown.Start == shown.End {
+			shown.End.Column++
+		}
+		editor.Cursors = []*core.Cursor{{Range: shown}}
+		renderer.ChangeStatus(fmt.Sprintf("replace with %q? (y/n/a/q)", string(match.Replacement)), true)
+		renderer.RenderEditor(editor)
 
-	for {
-		renderer.RenderCommand(command, cursor)
 		event := getEvent()
 		for event.EventType != input.KeyPressed {
 			event = getEvent()
 		}
 		switch event.Chr {
-		case input.KEY_UP:
-			break
-		case input.KEY_DOWN:
-			break
-		case input.KEY_LEFT:
-			 if cursor > 0 {
-				 cursor--
-			 }
-			break
-		case input.KEY_RIGHT:
-			 if cursor < len(command) {
-				 cursor++
-			 }
-			break
-		case input.ENTER:
-			statusText, statusOk = runCommand(command)
-			renderer.ChangeStatus(statusText, statusOk)
-			return
-		case input.ESCAPE:
-			return
-		case input.BACKSPACE:
-			if cursor > 0 {
-				command = command[:cursor - 1] + command[cursor:]
-				cursor--
-			}
-			break
-		default:
-			command = command[:cursor] + string(event.Chr) + command[cursor:]
-			cursor++
-			break
+		case 'y':
+			accepted = append(accepted, match)
+		case 'a':
+			return append(accepted, matches[i:]...)
+		case 'q', input.ESCAPE:
+			return accepted
 		}
 	}
+	return accepted
+}
+
+// Replaces each selection (or line, see core.SelectionReplacements) with the
+// output of the shell command, which gets it as input
+func pipeSelections(shellCommand string) (string, bool) {
+	if err := checkChangeable(); err != nil {
+		return err.Error(), false
+	}
+	matches, err := core.SelectionReplacements(editor, func(text string) (string, error) {
+		return shellFilter(shellCommand, text)
+	})
+	if err != nil {
+		return err.Error(), false
+	}
+	editor.MarkLabeledUndo("filter")
+	core.ReplaceMatches(matches)(editor)
+	return fmt.Sprintf("%v selections", len(matches)), true
 }
 
+// Compiles the regex in the arguments, and adds the cursors for its matches
+func matchCursors(args []string, edit func(*regexp.Regexp) core.Edit) (string, bool) {
+	if len(args) < 2 {
+		return "please provide a regex", false
+	}
+	regex, err := regexp.Compile(strings.Join(args[1:], " "))
+	if err != nil {
+		return err.Error(), false
+	}
+	edit(regex)(editor)
+	return fmt.Sprintf("%v cursors", len(editor.Cursors)), true
+}
 
+// Quits, unless there are unsaved changes and it is not forced
+func quitAll(force bool) (string, bool) {
+	modified := modifiedBuffers()
+	if !force && len(modified) > 0 {
+		return strings.Join(modified, ", ") + " has unsaved changes (add ! to override)", false
+	}
+	quit()
+	return "", true
+}
+
+// Runs the command, which can start with a range (see core.ParseRange). A
+// range alone moves to its last line.
 func runCommand(command string) (output string, ok bool) {
-	args := strings.Split(command, " ")
-	if len(args) == 0 {
-		return
+	rangee, rest, err := core.ParseRange(editor, command)
+	// "/pattern" is a search, unless it is the address of a command
+	isSearch := strings.HasPrefix(command, "/") && (err != nil || rest == "")
+	if isSearch {
+		rangee, rest, err = nil, command, nil
 	}
-	function, ok := commands[args[0]]
-	if !ok && len(args[0]) > 0 {
-		function, ok = commands[string(args[0][0])]
+	if err != nil {
+		return err.Error(), false
 	}
-	if !ok {
-		return `command "` + args[0] + `" not found`, false
+	if rangee != nil && rest == "" {
+		core.OnlyMainCursor(editor)
+		core.GoTo(core.Position(rangee.End, 0, rangee.End, 1))(editor)
+		return "", true
+	}
+
+	args := strings.Split(rest, " ")
+	name := args[0]
+	// Aliases are expanded once, so they can't loop
+	if alias, ok := aliases[name]; ok && !expandingAlias {
+		expandingAlias = true
+		defer func() { expandingAlias = false }()
+		return runCommand(command[:len(command) - len(rest)] + alias + rest[len(name):])
+	}
+	rangeFunction, ranged := rangeCommands[name]
+	function, ok := commands[name]
+	// So that for example "q!" runs "q", and "s/a/b/" runs "s"
+	if !ranged && !ok && len(name) > 0 {
+		rangeFunction, ranged = rangeCommands[name[:1]]
+		function, ok = commands[name[:1]]
+	}
+	switch {
+	case ranged:
+		return rangeFunction(rangee, args)
+	case !ok:
+		return `command "` + name + `" not found`, false
+	case rangee != nil:
+		return `command "` + name + `" doesn't take a range`, false
 	}
 	return function(args)
 }
 
 var commands = map[string] func([]string)(output string, ok bool) {
 	"w": func(args []string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
 		if len(args) > 1 {
-			editor.Global["Filename"] = strings.Join(args[1:], " ")
+			current().filename = strings.Join(args[1:], " ")
 		}
-		err := core.SaveToFile(editor, editor.Global["Filename"].(string))
+		undoErr, err := save(current().filename)
 		if err != nil {
 			return err.Error(), false
+		} else if undoErr != nil {
+			return fmt.Sprintf("saved %v, but not its undo file: %v", current().filename, undoErr), true
 		}
-		return "saved " + editor.Global["Filename"].(string), true
+		return "saved " + current().filename, true
 	},
-	"q": func([]string) (string, bool) {
-		quit()
-		return "", true
+	"q": func(args []string) (string, bool) {
+		return quitAll(args[0] == "q!")
 	},
 	"wq": func([]string) (string, bool) {
-		err := core.SaveToFile(editor, editor.Global["Filename"].(string))
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		// The undo file is lost anyway when quitting
+		_, err := save(current().filename)
 		if err != nil {
 			return err.Error(), false
 		}
-		quit()
+		return quitAll(false)
+	},
+	"e": func(args []string) (string, bool) {
+		if len(args) < 2 {
+			return reload(args[0] == "e!")
+		}
+		if err := openFile(strings.Join(args[1:], " ")); err != nil {
+			return err.Error(), false
+		}
+		return "opened " + current().filename, true
+	},
+	"split": func(args []string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		splitWindow(false)
+		if len(args) > 1 {
+			if err := openFile(strings.Join(args[1:], " ")); err != nil {
+				return err.Error(), false
+			}
+		}
+		return "", true
+	},
+	"vsplit": func(args []string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		splitWindow(true)
+		if len(args) > 1 {
+			if err := openFile(strings.Join(args[1:], " ")); err != nil {
+				return err.Error(), false
+			}
+		}
+		return "", true
+	},
+	"close": func([]string) (string, bool) {
+		return closeWindow()
+	},
+	"ls": func([]string) (string, bool) {
+		return listBuffers(), true
+	},
+	"b": func(args []string) (string, bool) {
+		if len(args) != 2 {
+			return "please provide exactly one buffer number", false
+		}
+		number, err := strconv.Atoi(args[1])
+		if err != nil || number < 1 || number > len(openBuffers) {
+			return "no buffer " + args[1], false
+		}
+		switchBuffer(number - 1)
+		return current().filename, true
+	},
+	"bn": func([]string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		cycleBuffer(1)
+		return current().filename, true
+	},
+	"bp": func([]string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		cycleBuffer(-1)
+		return current().filename, true
+	},
+	"bd": func([]string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		if editor.Modified() {
+			return current().filename + " has unsaved changes (add ! to override)", false
+		}
+		closeBuffer(currentBuffer)
+		return "", true
+	},
+	"bd!": func([]string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
+		closeBuffer(currentBuffer)
 		return "", true
 	},
 	"hello": func([]string) (string, bool) {
@@ -105,26 +557,106 @@ var commands = map[string] func([]string)(output string, ok bool) {
 		if err != nil {
 			return err.Error(), false
 		}
-		editor.Global["Regex"] = regex
+		searchRegex = regex
 		return "", true
 	},
-	"!": func(args []string) (string, bool) {
-		command := exec.Command("/bin/sh", "-c", strings.Join(args, " ")[1:])
-		command.Stdin = core.NewEditorReader(editor, 0, 0)
-		command.Stderr = io.Discard
-		stdout, err := command.Output()
+	"|": func(args []string) (string, bool) {
+		shellCommand := strings.Join(args, " ")[1:]
+		if strings.TrimSpace(shellCommand) == "" {
+			return "please provide a command", false
+		}
+		return pipeSelections(shellCommand)
+	},
+	"make": makeCommand,
+	"grep": grepCommand,
+	"cfile": cfileCommand,
+	"search": searchCommand,
+	"cn": func([]string) (string, bool) {
+		return jumpToEntry(quickfixIndex + 1)
+	},
+	"cp": func([]string) (string, bool) {
+		return jumpToEntry(quickfixIndex - 1)
+	},
+	"cc": func(args []string) (string, bool) {
+		if len(args) < 2 && quickfixIndex < 0 {
+			return jumpToEntry(0)
+		} else if len(args) < 2 {
+			return jumpToEntry(quickfixIndex)
+		}
+		number, err := strconv.Atoi(args[1])
 		if err != nil {
+			return "invalid number: " + args[1], false
+		}
+		return jumpToEntry(number - 1)
+	},
+	"errorformat": errorFormatCommand,
+	"jobs": func([]string) (string, bool) {
+		return listJobs()
+	},
+	"kill": killJob,
+	"match-all": func(args []string) (string, bool) {
+		return matchCursors(args, core.CursorPerMatch)
+	},
+	"match-selections": func(args []string) (string, bool) {
+		return matchCursors(args, core.CursorPerMatchInSelections)
+	},
+	"undo": func(args []string) (string, bool) {
+		if err := checkChangeable(); err != nil {
 			return err.Error(), false
 		}
-		editor.MarkUndo()
-		// FIXME: This is a horribly unefficient way to do this
-		for editor.Buffer.GetLength() > 0 {
-			editor.Buffer.RemoveLine(0)
+		if len(args) == 1 {
+			editor.Undo()
+			return "", true
 		}
-		for i, line := range strings.Split(string(stdout), "\n") {
-			editor.Buffer.AddLine(i, []rune(line))
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err.Error(), false
 		}
-		return "", false
+		err = editor.GoToVersion(version)
+		if err != nil {
+			return err.Error(), false
+		}
+		return "", true
+	},
+	"redo": func([]string) (string, bool) {
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.Redo()
+		return "", true
+	},
+	"earlier": func(args []string) (string, bool) {
+		return travel(args, -1)
+	},
+	"later": func(args []string) (string, bool) {
+		return travel(args, 1)
+	},
+	"undolist": func([]string) (string, bool) {
+		branches := []string{}
+		for _, branch := range editor.Branches() {
+			current := ""
+			if branch.Tip == editor.History.Current {
+				current = "*"
+			}
+			branches = append(branches, fmt.Sprintf(
+				"%v%v %v %v (%v changes, %v lines)",
+				current,
+				branch.Tip.Version,
+				branch.Tip.Label,
+				branch.Tip.Time.Format("15:04:05"),
+				branch.Length,
+				branch.ChangedLines,
+			))
+		}
+		return strings.Join(branches, " | "), true
+	},
+	"history-stats": func([]string) (string, bool) {
+		return fmt.Sprintf(
+			"%v versions, %v branches, ~%v KiB",
+			len(editor.History.Revisions),
+			len(editor.Branches()),
+			editor.HistorySize() / 1024,
+		), true
 	},
 	"cursor": func(args []string) (string, bool) {
 		cursor := editor.Cursors[len(editor.Cursors)-1]
@@ -137,6 +669,9 @@ var commands = map[string] func([]string)(output string, ok bool) {
 		), true
 	},
 	"treesitter": func([]string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
 		cursor := editor.Cursors[len(editor.Cursors)-1]
 		captures := buffer.GetCaptures(cursor.Start.Row, cursor.Start.Row+1)
 		output := ""
@@ -146,6 +681,9 @@ var commands = map[string] func([]string)(output string, ok bool) {
 		return output, true
 	},
 	"language": func(args []string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
 		if len(args) != 2 {
 			return "please provide exactly one language", false
 		}
@@ -154,9 +692,16 @@ var commands = map[string] func([]string)(output string, ok bool) {
 			return err.Error(), false
 		}
 		buffer.SetLanguage(*lang)
+		current().language = args[1]
+		if err := runLanguageCommands(args[1]); err != nil {
+			return err.Error(), false
+		}
 		return "set language to " + args[1], true
 	},
 	"syntax": func(args []string) (string, bool) {
+		if len(openBuffers) == 0 {
+			return errNoBuffer.Error(), false
+		}
 		if len(args) != 2 {
 			return "please provide exactly one provider (none or treesitter)", false
 		} else if args[1] == "none" {
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTravelUnits(t *testing.T) {
+	startSession(t, "text\n")
+	for _, arg := range []string{"5ms", "5sm", "5fs", "s", "-1", "+1", "1.5m"} {
+		output, ok := runCommand("earlier " + arg)
+		assert.False(t, ok, arg)
+		assert.Equal(t, "invalid count: " + arg, output)
+	}
+	for _, arg := range []string{"2", "5s", "5m", "1h", "1d", "1f"} {
+		_, ok := runCommand("earlier " + arg)
+		assert.True(t, ok, arg)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhhhhhhhhn/hexes"
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// $XDG_CONFIG_HOME/wr/config, or ~/.config/wr/config
+func configFilename() string {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(config, "wr", "config")
+}
+
+// Runs each line of the file as a command, before the files are opened, so
+// the ones that edit work on an empty buffer, and the ones that need a file,
+// like :w, fail. Empty lines and the ones
+// starting with # or " are skipped, and the : of commands is optional. A
+// missing file is not an error, and the errors of the lines are returned
+// together, with their line numbers.
+func runConfig(filename string) error {
+	contents, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if editor == nil {
+		scratch := core.NewBuffer()
+		core.LoadLines(scratch, [][]rune{{}})
+		editor = &core.Editor{Buffer: scratch, Config: editorConfig}
+		core.SetCursors(0, 0, 0, 1)(editor)
+	}
+	failed := []string{}
+	for i, line := range strings.Split(string(contents), "\n") {
+		command := strings.TrimRight(strings.TrimLeft(line, " \t"), "\r")
+		if command == "" || command[0] == '#' || command[0] == '"' {
+			continue
+		}
+		command = strings.TrimPrefix(command, ":")
+		if output, ok := runCommand(command); !ok {
+			failed = append(failed, fmt.Sprintf("%v:%v: %v", filepath.Base(filename), i + 1, output))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, " | "))
+	}
+	return nil
+}
+
+// Commands that run others (see :alias), by name
+var aliases = map[string]string{}
+var expandingAlias bool
+
+func aliasCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		list := []string{}
+		for name, command := range aliases {
+			list = append(list, name + " = " + command)
+		}
+		sort.Strings(list)
+		return strings.Join(list, " | "), true
+	}
+	name := args[1]
+	if len(args) == 2 {
+		command, ok := aliases[name]
+		if !ok {
+			return "no alias " + name, false
+		}
+		return name + " = " + command, true
+	}
+	aliases[name] = strings.Join(args[2:], " ")
+	return "", true
+}
+
+// Commands run when a buffer of the language is opened, or changed to it
+// with :language
+var languageCommands = map[string][]string{}
+
+func onLanguageCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "please provide a language", false
+	}
+	language := args[1]
+	if len(args) == 2 {
+		return strings.Join(languageCommands[language], " | "), true
+	}
+	languageCommands[language] = append(languageCommands[language], strings.Join(args[2:], " "))
+	return "", true
+}
+
+// Set in init, as the commands that open files would refer to themselves
+var runLanguageCommand func(string) (string, bool)
+
+// Runs the commands for the language in the current buffer, returning the
+// first error
+func runLanguageCommands(language string) error {
+	for _, command := range languageCommands[language] {
+		if output, ok := runLanguageCommand(command); !ok {
+			return fmt.Errorf("%v: %v", command, output)
+		}
+	}
+	return nil
+}
+
+// The attributes of each kind of capture, which is the start of their names
+// (see getAttribute), and the words that describe them (see parseAttribute)
+var highlightAttributes = map[string]hexes.Attribute{}
+var highlightWords = map[string]string{}
+
+func init() {
+	defaults := map[string]string{
+		"type":     "yellow",
+		"string":   "blue italic",
+		"keyword":  "green",
+		"comment":  "black bold italic",
+		"number":   "cyan",
+		"property": "blue",
+	}
+	for kind, words := range defaults {
+		setHighlight(kind, strings.Fields(words))
+	}
+	runLanguageCommand = runCommand
+	commands["alias"] = aliasCommand
+	commands["onlanguage"] = onLanguageCommand
+	commands["highlight"] = highlightCommand
+}
+
+func setHighlight(kind string, words []string) error {
+	attribute, err := parseAttribute(words)
+	if err != nil {
+		return err
+	}
+	highlightAttributes[kind] = attribute
+	highlightWords[kind] = strings.Join(words, " ")
+	return nil
+}
+
+func highlightCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		list := []string{}
+		for kind, words := range highlightWords {
+			list = append(list, kind + " " + words)
+		}
+		sort.Strings(list)
+		return strings.Join(list, " | "), true
+	}
+	kind := args[1]
+	if len(args) == 2 {
+		words, ok := highlightWords[kind]
+		if !ok {
+			return "no highlight for " + kind, false
+		}
+		return kind + " " + words, true
+	}
+	if err := setHighlight(kind, args[2:]); err != nil {
+		return err.Error(), false
+	}
+	return "", true
+}
+
+var attributeNames = map[string]hexes.Attribute{
+	"normal":    hexes.NORMAL,
+	"bold":      hexes.BOLD,
+	"faint":     hexes.FAINT,
+	"italic":    hexes.ITALIC,
+	"underline": hexes.UNDERLINE,
+	"reverse":   hexes.REVERSE,
+	"black":     hexes.BLACK,
+	"red":       hexes.RED,
+	"green":     hexes.GREEN,
+	"yellow":    hexes.YELLOW,
+	"blue":      hexes.BLUE,
+	"magenta":   hexes.MAGENTA,
+	"cyan":      hexes.CYAN,
+	"white":     hexes.WHITE,
+	"bg-black":   hexes.BG_BLACK,
+	"bg-red":     hexes.BG_RED,
+	"bg-green":   hexes.BG_GREEN,
+	"bg-yellow":  hexes.BG_YELLOW,
+	"bg-blue":    hexes.BG_BLUE,
+	"bg-magenta": hexes.BG_MAGENTA,
+	"bg-cyan":    hexes.BG_CYAN,
+	"bg-white":   hexes.BG_WHITE,
+}
+
+// Joins the attributes named by the words, which can also be colors like
+// #ff8000, or bg-#ff8000 for the background
+func parseAttribute(words []string) (hexes.Attribute, error) {
+	attribute := hexes.NORMAL
+	for _, word := range words {
+		if named, ok := attributeNames[word]; ok {
+			attribute = hexes.Join(attribute, named)
+			continue
+		}
+		color := strings.TrimPrefix(word, "bg-")
+		value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+		if !strings.HasPrefix(color, "#") || len(color) != 7 || err != nil {
+			return nil, fmt.Errorf("unknown attribute %q", word)
+		}
+		red, green, blue := int(value >> 16), int(value >> 8 & 0xff), int(value & 0xff)
+		if color == word {
+			attribute = hexes.Join(attribute, hexes.TrueColor(red, green, blue))
+		} else {
+			attribute = hexes.Join(attribute, hexes.TrueColorBg(red, green, blue))
+		}
+	}
+	return attribute, nil
+}
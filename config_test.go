@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigWithoutFile(t *testing.T) {
+	startBatch(t)
+	defer func() { aliases = map[string]string{} }()
+	filename := filepath.Join(t.TempDir(), "config")
+	config := "alias x hello\n\n# comment\nw\nbn\nbd\nsplit\ne\n"
+	assert.Nil(t, os.WriteFile(filename, []byte(config), 0644))
+	err := runConfig(filename)
+	assert.Equal(t, "config:4: no file is open | config:5: no file is open | " +
+		"config:6: no file is open | config:7: no file is open | config:8: no file is open", err.Error())
+	assert.Equal(t, "hello", aliases["x"])
+}
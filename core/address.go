@@ -0,0 +1,194 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A range of rows, both included
+type LineRange struct {
+	Start int
+	End   int
+}
+
+var ErrInvalidRange = errors.New("invalid range")
+var ErrMarkNotSet = errors.New("mark not set")
+var ErrPatternNotFound = errors.New("pattern not found")
+
+func (e *Editor) SetMark(name rune, location Location) {
+	if e.Marks == nil {
+		e.Marks = make(map[rune]Location)
+	}
+	e.Marks[name] = location
+}
+
+// The row of the last cursor, or 0 if there are none
+func CurrentRow(editor *Editor) int {
+	if len(editor.Cursors) == 0 {
+		return 0
+	}
+	return editor.Cursors[len(editor.Cursors)-1].Start.Row
+}
+
+// Parses the range at the start of an Ex command, returning it and the rest
+// of the command. If the command has no range, it returns nil. Addresses are
+// one-indexed, like in Vim:
+//
+//	N      line N
+//	.      the current line (of the last cursor)
+//	$      the last line
+//	'x     mark x, or '< and '> for the selection of the last cursor
+//	/re/   the next line matching re, wrapping around
+//	?re?   the previous line matching re, wrapping around
+//	%      the whole buffer, same as 1,$
+//
+// Each can be followed by offsets like +N or -N. Two addresses are separated
+// by a comma, or by a semicolon to search the second one from the first.
+func ParseRange(editor *Editor, command string) (*LineRange, string, error) {
+	if strings.HasPrefix(command, "%") {
+		return &LineRange{0, editor.Buffer.GetLength() - 1}, command[1:], nil
+	}
+	current := CurrentRow(editor)
+	start, rest, ok, err := ParseAddress(editor, command, current)
+	if err != nil {
+		return nil, command, err
+	}
+	if !ok {
+		start = current
+	}
+	if !strings.HasPrefix(rest, ",") && !strings.HasPrefix(rest, ";") {
+		if !ok {
+			return nil, command, nil
+		}
+		return checkRange(editor, LineRange{start, start}, rest)
+	}
+	if rest[0] == ';' {
+		current = start
+	}
+	end, rest, ok, err := ParseAddress(editor, rest[1:], current)
+	if err != nil {
+		return nil, command, err
+	}
+	if !ok {
+		end = current
+	}
+	if end < start {
+		start, end = end, start
+	}
+	return checkRange(editor, LineRange{start, end}, rest)
+}
+
+func checkRange(editor *Editor, rangee LineRange, rest string) (*LineRange, string, error) {
+	if rangee.Start < 0 || rangee.End >= editor.Buffer.GetLength() {
+		return nil, rest, ErrInvalidRange
+	}
+	return &rangee, rest, nil
+}
+
+var number = regexp.MustCompile(`^[0-9]+`)
+
+// Parses a single address (see ParseRange), returning its row and the rest of
+// the command. Address 0 gives row -1, for commands that insert after it.
+// If there is no address, ok is false.
+func ParseAddress(editor *Editor, command string, current int) (row int, rest string, ok bool, err error) {
+	rest = command
+	ok = true
+	switch {
+	case number.MatchString(command):
+		digits := number.FindString(command)
+		row, _ = strconv.Atoi(digits)
+		row--
+		rest = command[len(digits):]
+	case strings.HasPrefix(command, "."):
+		row = current
+		rest = command[1:]
+	case strings.HasPrefix(command, "$"):
+		row = editor.Buffer.GetLength() - 1
+		rest = command[1:]
+	case strings.HasPrefix(command, "'"):
+		name, size := firstRune(command[1:])
+		row, err = markRow(editor, name)
+		if err != nil {
+			return 0, command, false, err
+		}
+		rest = command[1+size:]
+	case strings.HasPrefix(command, "/"), strings.HasPrefix(command, "?"):
+		var pattern string
+		pattern, rest, _ = untilDelimiter(command[1:], rune(command[0]))
+		row, err = searchRow(editor, pattern, current, command[0] == '/')
+		if err != nil {
+			return 0, command, false, err
+		}
+	case strings.HasPrefix(command, "+"), strings.HasPrefix(command, "-"):
+		row = current
+	default:
+		return 0, command, false, nil
+	}
+
+	// Offsets
+	for len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+		sign := 1
+		if rest[0] == '-' {
+			sign = -1
+		}
+		digits := number.FindString(rest[1:])
+		offset := 1
+		if digits != "" {
+			offset, _ = strconv.Atoi(digits)
+		}
+		row += sign * offset
+		rest = rest[1+len(digits):]
+	}
+	return row, rest, true, nil
+}
+
+func firstRune(str string) (rune, int) {
+	for _, chr := range str {
+		return chr, len(string(chr))
+	}
+	return 0, 0
+}
+
+func markRow(editor *Editor, name rune) (int, error) {
+	switch name {
+	case 0:
+		return 0, ErrInvalidRange
+	case '<', '>':
+		if len(editor.Cursors) == 0 {
+			return 0, ErrMarkNotSet
+		}
+		cursor := editor.Cursors[len(editor.Cursors)-1]
+		if name == '<' {
+			return cursor.Start.Row, nil
+		}
+		return cursor.End.Row, nil
+	}
+	location, ok := editor.Marks[name]
+	if !ok {
+		return 0, ErrMarkNotSet
+	}
+	return location.Row, nil
+}
+
+// Returns the first row after (or before, if not forward) the current one
+// matching the pattern, wrapping around the buffer
+func searchRow(editor *Editor, pattern string, current int, forward bool) (int, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, err
+	}
+	length := editor.Buffer.GetLength()
+	step := 1
+	if !forward {
+		step = -1
+	}
+	for i := 1; i <= length; i++ {
+		row := ((current + i * step) % length + length) % length
+		if regex.MatchString(string(editor.Buffer.GetLine(row))) {
+			return row, nil
+		}
+	}
+	return 0, ErrPatternNotFound
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addressTestEditor() *Editor {
+	e := substituteTestEditor("zero", "one", "two", "three", "four", "five")
+	e.Cursors = nil
+	SetCursors(2,0,2,1)(e)
+	return e
+}
+
+func TestParseRange(t *testing.T) {
+	e := addressTestEditor()
+	e.SetMark('a', Location{1, 2})
+
+	tests := []struct {
+		command string
+		rangee  LineRange
+		rest    string
+	}{
+		{"3,5d", LineRange{2, 4}, "d"},
+		{".,$", LineRange{2, 5}, ""},
+		{"%s/a/b/", LineRange{0, 5}, "s/a/b/"},
+		{"'a,.+2j", LineRange{1, 4}, "j"},
+		{"/f/,/f/m0", LineRange{4, 4}, "m0"},
+		{"/f/;/f/", LineRange{4, 5}, ""},
+		{"?o?", LineRange{1, 1}, ""},
+		{"5,2", LineRange{1, 4}, ""},
+		{",+", LineRange{2, 3}, ""},
+		{"-", LineRange{1, 1}, ""},
+	}
+	for _, test := range tests {
+		rangee, rest, err := ParseRange(e, test.command)
+		assert.Nil(t, err, test.command)
+		assert.Equal(t, &test.rangee, rangee, test.command)
+		assert.Equal(t, test.rest, rest, test.command)
+	}
+
+	rangee, rest, err := ParseRange(e, "w file")
+	assert.Nil(t, err)
+	assert.Nil(t, rangee)
+	assert.Equal(t, "w file", rest)
+
+	_, _, err = ParseRange(e, "1,10")
+	assert.Equal(t, ErrInvalidRange, err)
+	_, _, err = ParseRange(e, "'b")
+	assert.Equal(t, ErrMarkNotSet, err)
+	_, _, err = ParseRange(e, "/nothing/")
+	assert.Equal(t, ErrPatternNotFound, err)
+}
+
+func TestParseAddressZero(t *testing.T) {
+	e := addressTestEditor()
+	row, rest, ok, err := ParseAddress(e, "0", 2)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, -1, row)
+	assert.Equal(t, "", rest)
+}
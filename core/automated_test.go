@@ -66,19 +66,19 @@ func testScenario(t *testing.T, s scenario, ce func() CursorEdit) {
 	updatedCursors := cursors(editor)
 
 	editor.Undo()
-	assert.Equal(t, originalLines, editor.Buffer.Current.Value())
+	assert.Equal(t, originalLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, originalCursors, cursors(editor))
 
 	editor.Redo()
-	assert.Equal(t, updatedLines, editor.Buffer.Current.Value())
+	assert.Equal(t, updatedLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, updatedCursors, cursors(editor))
 
 	editor.Undo()
-	assert.Equal(t, originalLines, editor.Buffer.Current.Value())
+	assert.Equal(t, originalLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, originalCursors, cursors(editor))
 
 	editor.Redo()
-	assert.Equal(t, updatedLines, editor.Buffer.Current.Value())
+	assert.Equal(t, updatedLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, updatedCursors, cursors(editor))
 
 
@@ -92,19 +92,19 @@ func testScenario(t *testing.T, s scenario, ce func() CursorEdit) {
 	updatedCursors = cursors(editor)
 
 	editor.Undo()
-	assert.Equal(t, originalLines, editor.Buffer.Current.Value())
+	assert.Equal(t, originalLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, originalCursors, cursors(editor))
 
 	editor.Redo()
-	assert.Equal(t, updatedLines, editor.Buffer.Current.Value())
+	assert.Equal(t, updatedLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, updatedCursors, cursors(editor))
 
 	editor.Undo()
-	assert.Equal(t, originalLines, editor.Buffer.Current.Value())
+	assert.Equal(t, originalLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, originalCursors, cursors(editor))
 
 	editor.Redo()
-	assert.Equal(t, updatedLines, editor.Buffer.Current.Value())
+	assert.Equal(t, updatedLines, editor.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, updatedCursors, cursors(editor))
 
 	editor.Undo()
@@ -12,6 +12,12 @@ type Buffer interface {
 	GetLength() int
 	Backup(destination Version)
 	Restore(source Version)
+	GetVersion(version Version) [][]rune
+	SetVersion(version Version, lines [][]rune)
+	DeleteVersion(version Version)
+	// Whether the contents are still the ones of the version, as backed up
+	// or restored, without comparing them
+	IsVersion(version Version) bool
 }
 
 type BufferValue = *Rope[[]rune]
@@ -57,8 +63,38 @@ func (b *BaseBuffer) Restore(source Version) {
 	b.Current = b.Versions[source]
 }
 
+// Returns the lines of a backed up version, without restoring it
+func (b *BaseBuffer) GetVersion(version Version) [][]rune {
+	return b.Versions[version].Value()
+}
+
+// Creates a backup from the lines, without changing the current value
+func (b *BaseBuffer) SetVersion(version Version, lines [][]rune) {
+	b.Versions[version] = NewRope(lines, DefaultSettings)
+}
+
+func (b *BaseBuffer) DeleteVersion(version Version) {
+	delete(b.Versions, version)
+}
+
+// The values are immutable, so any change gives a different one
+func (b *BaseBuffer) IsVersion(version Version) bool {
+	return b.Current == b.Versions[version]
+}
+
 var _ Buffer = (*BaseBuffer)(nil) // Type Checking
 
+// Used by LoadLines, as the VersionAllocator never gives it
+const loadVersion Version = 0
+
+// Replaces the contents of the buffer with the lines. It is much faster than
+// adding them one by one, and the result is balanced.
+func LoadLines(buffer Buffer, lines [][]rune) {
+	buffer.SetVersion(loadVersion, lines)
+	buffer.Restore(loadVersion)
+	buffer.DeleteVersion(loadVersion)
+}
+
 func NewBuffer() *BaseBuffer {
 	return &BaseBuffer{
 		Current: NewRope([][]rune{}, DefaultSettings),
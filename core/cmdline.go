@@ -0,0 +1,157 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The commands run before, oldest first, without duplicates
+type CommandHistory struct {
+	Entries []string
+	Max     int // The oldest ones are dropped after this
+}
+
+func NewCommandHistory(max int) *CommandHistory {
+	return &CommandHistory{Entries: []string{}, Max: max}
+}
+
+// Reads a history saved with Save. A missing file is an empty history.
+func LoadCommandHistory(filename string, max int) (*CommandHistory, error) {
+	history := NewCommandHistory(max)
+	contents, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return history, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		history.Add(line)
+	}
+	return history, nil
+}
+
+// Writes one entry per line, creating the directory if needed
+func (h *CommandHistory) Save(filename string) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	contents := ""
+	for _, entry := range h.Entries {
+		contents += entry + "\n"
+	}
+	return os.WriteFile(filename, []byte(contents), 0644)
+}
+
+// Adds the entry as the newest, removing it from where it was before. Empty
+// entries are ignored.
+func (h *CommandHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" || strings.Contains(entry, "\n") {
+		return
+	}
+	for i, old := range h.Entries {
+		if old == entry {
+			h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
+			break
+		}
+	}
+	h.Entries = append(h.Entries, entry)
+	if h.Max > 0 && len(h.Entries) > h.Max {
+		h.Entries = h.Entries[len(h.Entries)-h.Max:]
+	}
+}
+
+// Returns the index of the closest entry before (direction -1) or after
+// (direction 1) the index starting with the prefix. Not found is -1 going
+// back and len(Entries) going forward.
+func (h *CommandHistory) Find(prefix string, index int, direction int) int {
+	for i := index + direction; i >= 0 && i < len(h.Entries); i += direction {
+		if strings.HasPrefix(h.Entries[i], prefix) {
+			return i
+		}
+	}
+	if direction < 0 {
+		return -1
+	}
+	return len(h.Entries)
+}
+
+// Removes the word before the cursor, and the spaces after it. Runs of
+// punctuation are words too.
+func DeleteWordBefore(text []rune, cursor int) ([]rune, int) {
+	start := cursor
+	for start > 0 && unicode.IsSpace(text[start-1]) {
+		start--
+	}
+	if start > 0 {
+		isWord := isWordRune(text[start-1])
+		for start > 0 && !unicode.IsSpace(text[start-1]) && isWordRune(text[start-1]) == isWord {
+			start--
+		}
+	}
+	result := append([]rune{}, text[:start]...)
+	return append(result, text[cursor:]...), start
+}
+
+func isWordRune(chr rune) bool {
+	return chr == '_' || unicode.IsLetter(chr) || unicode.IsDigit(chr)
+}
+
+// Returns the candidates starting with the word, sorted and without
+// duplicates
+func Complete(word string, candidates []string) []string {
+	completions := []string{}
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) && !seen[candidate] {
+			seen[candidate] = true
+			completions = append(completions, candidate)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// Returns the longest prefix shared by all the words
+func CommonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// Returns the paths of the files starting with the partial path, with a
+// slash after directories. Hidden files are only included if the name
+// being completed starts with a dot.
+func CompletePath(partial string) []string {
+	dir, name := filepath.Split(partial)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return []string{}
+	}
+	candidates := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(name, ".") {
+			continue
+		}
+		candidate := dir + entry.Name()
+		if entry.IsDir() {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return Complete(partial, candidates)
+}
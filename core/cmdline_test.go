@@ -0,0 +1,80 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandHistory(t *testing.T) {
+	history := NewCommandHistory(3)
+	history.Add("w")
+	history.Add("e main.go")
+	history.Add("")
+	history.Add("w")
+	history.Add("s/a/b/")
+	history.Add("set tabsize=8")
+	assert.Equal(t, []string{"w", "s/a/b/", "set tabsize=8"}, history.Entries)
+
+	assert.Equal(t, 2, history.Find("s", 3, -1))
+	assert.Equal(t, 1, history.Find("s", 2, -1))
+	assert.Equal(t, -1, history.Find("s", 1, -1))
+	assert.Equal(t, 2, history.Find("s", 1, 1))
+	assert.Equal(t, 3, history.Find("s", 2, 1))
+
+	filename := filepath.Join(t.TempDir(), "state", "history")
+	loaded, err := LoadCommandHistory(filename, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, loaded.Entries)
+
+	assert.Nil(t, history.Save(filename))
+	loaded, err = LoadCommandHistory(filename, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"s/a/b/", "set tabsize=8"}, loaded.Entries)
+}
+
+func TestDeleteWordBefore(t *testing.T) {
+	tests := []struct {
+		text   string
+		cursor int
+		result string
+	}{
+		{"e main.go", 9, "e main."},
+		{"e main.", 7, "e main"},
+		{"e main  ", 8, "e "},
+		{"e ", 2, ""},
+		{"s/a/b/ x", 6, "s/a/b x"},
+		{"", 0, ""},
+	}
+	for _, test := range tests {
+		result, cursor := DeleteWordBefore([]rune(test.text), test.cursor)
+		assert.Equal(t, test.result, string(result), test.text)
+		assert.Equal(t, len([]rune(test.text)) - test.cursor, len(result) - cursor, test.text)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	candidates := []string{"vsplit", "b", "bn", "bd", "bd!", "bn"}
+	assert.Equal(t, []string{"b", "bd", "bd!", "bn"}, Complete("b", candidates))
+	assert.Equal(t, []string{}, Complete("x", candidates))
+	assert.Equal(t, "bd", CommonPrefix([]string{"bd", "bd!"}))
+	assert.Equal(t, "", CommonPrefix([]string{"bd", "vsplit"}))
+	assert.Equal(t, "", CommonPrefix(nil))
+}
+
+func TestCompletePath(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "modes.go", ".hidden", "core/editor.go"} {
+		path := filepath.Join(root, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte{}, 0644))
+	}
+	dir := root + "/"
+	assert.Equal(t, []string{dir + "core/", dir + "main.go", dir + "modes.go"}, CompletePath(dir))
+	assert.Equal(t, []string{dir + "main.go", dir + "modes.go"}, CompletePath(dir + "m"))
+	assert.Equal(t, []string{dir + ".hidden"}, CompletePath(dir + "."))
+	assert.Equal(t, []string{dir + "core/editor.go"}, CompletePath(dir + "core/e"))
+	assert.Equal(t, []string{}, CompletePath(dir + "missing/"))
+}
@@ -0,0 +1,124 @@
+package core
+
+// A change to the lines: the rows from Start to End (exclusive) are replaced
+// by the lines
+type Hunk struct {
+	Start int
+	End   int
+	Lines [][]rune
+}
+
+// Above this many added or removed lines, the rest is replaced as a single
+// hunk, as the diff takes quadratic memory in the amount of them
+const maxDiffEdits = 2000
+
+// Returns the hunks that change the old lines into the new ones, in order,
+// using Myers' algorithm
+func DiffLines(old, new [][]rune) []Hunk {
+	ids := map[string]int{}
+	lineIds := func(lines [][]rune) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[string(line)]
+			if !ok {
+				id = len(ids)
+				ids[string(line)] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+	a, b := lineIds(old), lineIds(new)
+
+	// The common start and end are skipped, which is most of it when the
+	// change is small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a) - prefix && suffix < len(b) - prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	new = new[prefix:len(new)-suffix]
+
+	hunks := []Hunk{}
+	// Matching lines, as x in a and y in b, between the sentinels
+	lastX, lastY := -1, -1
+	for _, match := range append(commonSubsequence(a, b), [2]int{len(a), len(b)}) {
+		x, y := match[0], match[1]
+		if x > lastX + 1 || y > lastY + 1 {
+			hunks = append(hunks, Hunk{
+				Start: prefix + lastX + 1,
+				End:   prefix + x,
+				Lines: new[lastY+1:y],
+			})
+		}
+		lastX, lastY = x, y
+	}
+	return hunks
+}
+
+// The pairs of indices of equal elements in the longest common subsequence,
+// in order, or none if there are too many differences
+func commonSubsequence(a, b []int) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+	offset := n + m + 1
+	v := make([]int, 2*offset + 1)
+	// Copies of the used part of v before each step, for backtracking
+	trace := [][]int{}
+
+	for d := 0; d <= n + m && d <= maxDiffEdits; d++ {
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, x, y int) [][2]int {
+	matches := [][2]int{}
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d] // Index i is k = i - d
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prevK := k - 1
+			if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+				prevK = k + 1
+			}
+			prevX = v[prevK+d]
+			prevY = prevX - prevK
+		}
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(matches) - 1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
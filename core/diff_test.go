@@ -0,0 +1,88 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func applyHunks(lines [][]rune, hunks []Hunk) [][]rune {
+	result := [][]rune{}
+	last := 0
+	for _, hunk := range hunks {
+		result = append(result, lines[last:hunk.Start]...)
+		result = append(result, hunk.Lines...)
+		last = hunk.End
+	}
+	return append(result, lines[last:]...)
+}
+
+func TestDiffLines(t *testing.T) {
+	old := ToRune([]string{"a", "b", "c", "d", "e", "f"})
+	new := ToRune([]string{"a", "x", "c", "d", "f", "g"})
+	assert.Equal(t, []Hunk{
+		{1, 2, ToRune([]string{"x"})},
+		{4, 5, [][]rune{}},
+		{6, 6, ToRune([]string{"g"})},
+	}, DiffLines(old, new))
+
+	assert.Equal(t, []Hunk{}, DiffLines(old, old))
+	assert.Equal(t, []Hunk{{0, 6, [][]rune{}}}, DiffLines(old, [][]rune{}))
+	assert.Equal(t, []Hunk{{0, 0, old}}, DiffLines([][]rune{}, old))
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	randomLines := func() [][]rune {
+		lines := make([][]rune, random.Intn(20))
+		for i := range lines {
+			lines[i] = []rune{rune('a' + random.Intn(4))}
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		old, new := randomLines(), randomLines()
+		hunks := DiffLines(old, new)
+		assert.Equal(t, new, applyHunks(old, hunks), "%v %v", old, new)
+	}
+}
+
+func TestReplaceLinesKeepsCursors(t *testing.T) {
+	e := addressTestEditor()
+	e.Cursors = nil
+	SetCursors(1,1,1,2, 3,0,3,1, 5,2,5,3)(e)
+	ReplaceLines(LineRange{0, 5}, ToRune([]string{"zero", "one", "three", "3", "four", "five"}))(e)
+	assert.Equal(t, ToRune([]string{"zero", "one", "three", "3", "four", "five"}), lines(e))
+	assert.Equal(t, []Range{
+		{Location{1, 1}, Location{1, 2}},
+		{Location{2, 0}, Location{2, 1}},
+		{Location{5, 2}, Location{5, 3}},
+	}, cursorRanges(e))
+}
+
+func TestSetLines(t *testing.T) {
+	e := substituteTestEditor("func main() {", "x := 1", "return", "}")
+	e.Config.Tabsize = 4
+	e.Cursors = nil
+	SetCursors(0,5,0,9, 1,5,1,6, 1,2,1,4, 2,0,2,6)(e)
+	SetLines(ToRune([]string{"func main() {", "\tx := 10", "}"}))(e)
+	assert.Equal(t, ToRune([]string{"func main() {", "\tx := 10", "}"}), lines(e))
+	assert.Equal(t, []Range{
+		{Location{0, 5}, Location{0, 9}},
+		{Location{1, 9}, Location{1, 10}},
+		{Location{1, 6}, Location{1, 8}},
+		{Location{1, 0}, Location{1, 6}},
+	}, cursorRanges(e))
+}
+
+func TestChangeLineInsideChange(t *testing.T) {
+	e := substituteTestEditor("abcdef")
+	e.Cursors = nil
+	SetCursors(0,2,0,3, 0,1,0,5)(e)
+	SetLines(ToRune([]string{"abXYZef"}))(e)
+	assert.Equal(t, []Range{
+		{Location{0, 2}, Location{0, 3}},
+		{Location{0, 1}, Location{0, 6}},
+	}, cursorRanges(e))
+}
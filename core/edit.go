@@ -1,6 +1,9 @@
 package core
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
 
 type Edit func(*Editor)
 type CursorEdit func(*Editor, *Cursor) // Only uses single cursor
@@ -135,6 +138,18 @@ func Insert(insertion []rune) CursorEdit {
 	}
 }
 
+// Inserts a tab, or if expand is set, the spaces up to the next tab stop
+func InsertTab(expand bool) CursorEdit {
+	return func(editor *Editor, cursor *Cursor) {
+		if !expand || editor.Config.Tabsize < 1 {
+			InsertInLine([]rune{'\t'})(editor, cursor)
+			return
+		}
+		spaces := editor.Config.Tabsize - cursor.Start.Column % editor.Config.Tabsize
+		InsertInLine([]rune(strings.Repeat(" ", spaces)))(editor, cursor)
+	}
+}
+
 func SmartSplit(editor *Editor, cursor *Cursor) {
 	indentation := GetIndentation(editor, cursor)
 	Split(editor, cursor)
@@ -21,10 +21,10 @@ func TestSplit(t *testing.T) {
 
 	expected := []string{"0000", "11", "11", "222", "2", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSplitOOBMultiline(t *testing.T) {
@@ -43,10 +43,10 @@ func TestSplitOOBMultiline(t *testing.T) {
 
 	expected := []string{"0000", "1", "111", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSplitOOB(t *testing.T) {
@@ -64,10 +64,10 @@ func TestSplitOOB(t *testing.T) {
 
 	expected := []string{"0000", "1111", "", "2222", "", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSplitEOF(t *testing.T) {
@@ -86,10 +86,10 @@ func TestSplitEOF(t *testing.T) {
 
 	expected := []string{"0000", "1", "111", "2" ,"222", "3", "333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSplitCursors(t *testing.T) {
@@ -114,11 +114,11 @@ func TestSplitCursors(t *testing.T) {
 		{Range: Range{Location{7,0},Location{7,3}}},
 	}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, expectedCursors, e.Cursors)
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSingleInsertInLine(t *testing.T) {
@@ -137,10 +137,10 @@ func TestSingleInsertInLine(t *testing.T) {
 
 	expected := []string{"0000", "11!11", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestInsertInLine(t *testing.T) {
@@ -165,11 +165,11 @@ func TestInsertInLine(t *testing.T) {
 		{Range: Range{Location{2,9},Location{2,10}}},
 	}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, expectedCursors, e.Cursors)
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestInsert(t *testing.T) {
@@ -188,10 +188,10 @@ func TestInsert(t *testing.T) {
 
 	expected := []string{"0000", "11!", "!11", "222!", "!2", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestInsertOOB(t *testing.T) {
@@ -210,10 +210,10 @@ func TestInsertOOB(t *testing.T) {
 
 	expected := []string{"0000", "1111!", "!", "2222!", "!", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSmartSplit(t *testing.T) {
@@ -232,10 +232,10 @@ func TestSmartSplit(t *testing.T) {
 
 	expected := []string{"0000", " 1", " 111", "  2", "  222", "   3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSingleDelete(t *testing.T) {
@@ -252,10 +252,10 @@ func TestSingleDelete(t *testing.T) {
 
 	expected := []string{"0000", "111", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSingleDeleteMultiline(t *testing.T) {
@@ -272,10 +272,10 @@ func TestSingleDeleteMultiline(t *testing.T) {
 
 	expected := []string{"0000", "122", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSingleDeleteJoin(t *testing.T) {
@@ -292,10 +292,10 @@ func TestSingleDeleteJoin(t *testing.T) {
 
 	expected := []string{"0000", "13333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSingleDeleteLastLine(t *testing.T) {
@@ -312,10 +312,10 @@ func TestSingleDeleteLastLine(t *testing.T) {
 
 	expected := []string{"0000", "1111", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestSingleDeleteCursors(t *testing.T) {
@@ -339,11 +339,11 @@ func TestSingleDeleteCursors(t *testing.T) {
 		{Range: Range{Location{1,3},Location{1,4}}},
 	}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 	assert.Equal(t, expectedCursors, e.Cursors)
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestDelete(t *testing.T) {
@@ -362,10 +362,10 @@ func TestDelete(t *testing.T) {
 
 	expected := []string{"0000", "111", "222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestDeleteMultiline(t *testing.T) {
@@ -384,10 +384,10 @@ func TestDeleteMultiline(t *testing.T) {
 
 	expected := []string{"0000", "112", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestDeleteJoinLines(t *testing.T) {
@@ -406,10 +406,10 @@ func TestDeleteJoinLines(t *testing.T) {
 
 	expected := []string{"0000", "11113333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestDeleteJoinLinesMultiline(t *testing.T) {
@@ -428,10 +428,10 @@ func TestDeleteJoinLinesMultiline(t *testing.T) {
 
 	expected := []string{"0123"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestDeleteOOB(t *testing.T) {
@@ -450,10 +450,10 @@ func TestDeleteOOB(t *testing.T) {
 
 	expected := []string{"0000", "11113333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestYankAndPaste(t *testing.T) {
@@ -474,10 +474,10 @@ func TestYankAndPaste(t *testing.T) {
 
 	expected := []string{"0000", "111", "221111", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 func TestYankEOL(t *testing.T) {
 	lines := []string{"0000", "1111", "2222", "3333"}
@@ -497,8 +497,22 @@ func TestYankEOL(t *testing.T) {
 
 	expected := []string{"0000", "11111111", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
+}
+
+func TestInsertTab(t *testing.T) {
+	b := NewBuffer()
+	b.Current = b.Current.Insert(0, ToRune([]string{"ab", "\tx", "abcd"}))
+	e := &Editor{Buffer: b, Config: EditorConfig{Tabsize: 4}}
+	e.MarkUndo()
+
+	SetCursors(0,2,0,3, 1,4,1,5, 2,4,2,5)(e)
+	AsEdit(InsertTab(true))(e)
+	assert.Equal(t, ToRune([]string{"ab  ", "\t    x", "abcd    "}), e.Buffer.(*BaseBuffer).Current.Value())
+
+	AsEdit(InsertTab(false))(e)
+	assert.Equal(t, ToRune([]string{"ab  \t", "\t    \tx", "abcd    \t"}), e.Buffer.(*BaseBuffer).Current.Value())
 }
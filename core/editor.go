@@ -2,30 +2,37 @@ package core
 
 import (
 	"sort"
-	"math/rand"
 	"io"
 	"os"
+	"time"
 
 	rw "github.com/mattn/go-runewidth"
 )
 
+// Registers are named by the keys from a, and macros are recorded to them too
+const RegisterCount = 30
+
 type Cursor struct {
 	Range
-	Registers [30][]rune
+	Registers [RegisterCount][]rune
 }
 
 type Editor struct {
 	Buffer          Buffer
-	History         []Version
-	HistoryIndex    int
+	History         UndoTree
+	Versions        VersionAllocator
 	Cursors         []*Cursor
 	CursorsVersions map[Version][]Cursor
 	Config          EditorConfig
-	Global          map[string]any // For changing values
+	Marks           map[rune]Location // Not moved by edits
 }
 
 type EditorConfig struct {
-	Tabsize int
+	Tabsize         int
+	// Limits for the undo history (see Prune), ignored if zero
+	MaxVersions     int
+	MaxVersionAge   time.Duration
+	MaxVersionBytes int
 }
 
 type Range struct {
@@ -39,45 +46,6 @@ type Location struct {
 	Column int
 }
 
-func (e *Editor) Undo() {
-	if e.HistoryIndex >= len(e.History) { // Current version is not saved
-		e.MarkUndo()
-		e.HistoryIndex--
-	}
-	if e.HistoryIndex > 0 {
-		e.HistoryIndex--
-	}
-	if e.HistoryIndex < len(e.History) {
-		e.restoreVersion(e.History[e.HistoryIndex])
-	}
-}
-
-func (e *Editor) Redo() {
-	if e.HistoryIndex < len(e.History) - 1 {
-		e.HistoryIndex++
-	}
-	if e.HistoryIndex < len(e.History) {
-		e.restoreVersion(e.History[e.HistoryIndex])
-	}
-}
-
-func (e *Editor) restoreVersion(version Version) {
-	e.Buffer.Restore(version)
-	e.Cursors = restoreCursors(e.CursorsVersions[version])
-}
-
-// Marks the start of an action to be undone
-func (e *Editor) MarkUndo() {
-	if e.CursorsVersions == nil {
-		e.CursorsVersions = make(map[int][]Cursor)
-	}
-	newVersion := rand.Int()
-	e.Buffer.Backup(newVersion)
-	e.CursorsVersions[newVersion] = backupCursors(e.Cursors)
-	e.HistoryIndex++
-	e.History = append(e.History[:e.HistoryIndex-1], newVersion)
-}
-
 func backupCursors(cursors []*Cursor) []Cursor {
 	backup := make([]Cursor, len(cursors))
 	for i, cursor := range cursors {
@@ -86,6 +54,11 @@ func backupCursors(cursors []*Cursor) []Cursor {
 	return backup
 }
 
+// Returns cursors that can be changed without affecting the original ones
+func CopyCursors(cursors []*Cursor) []*Cursor {
+	return restoreCursors(backupCursors(cursors))
+}
+
 func restoreCursors(cursors []Cursor) []*Cursor {
 	restored := make([]*Cursor, len(cursors))
 	for i, cursor := range cursors {
@@ -134,6 +107,38 @@ func ColumnSpan(editor *Editor, line []rune) (column int) {
 	return column
 }
 
+// Changes the width of tabs, moving the cursors so they stay on the same
+// characters, as their columns count tabs with that width
+func SetTabsize(size int) Edit {
+	return func(editor *Editor) {
+		type position struct {
+			location *Location
+			line     []rune
+			index    int
+			overflow int // Past the end, like a selected newline
+		}
+		positions := []position{}
+		for _, cursor := range editor.Cursors {
+			for _, location := range []*Location{&cursor.Start, &cursor.End} {
+				line := editor.Buffer.GetLine(location.Row)
+				positions = append(positions, position{
+					location: location,
+					line:     line,
+					index:    ColumnToIndex(editor, line, location.Column),
+					overflow: location.Column - ColumnSpan(editor, line),
+				})
+			}
+		}
+		editor.Config.Tabsize = size
+		for _, p := range positions {
+			p.location.Column = ColumnSpan(editor, p.line[:p.index])
+			if p.overflow > 0 {
+				p.location.Column += p.overflow
+			}
+		}
+	}
+}
+
 func SortCursors(cursors []*Cursor) (sortedCursors []*Cursor) {
 	sortedCursors = make([]*Cursor, len(cursors))
 	copy(sortedCursors, cursors)
@@ -169,5 +174,10 @@ func SaveToFile(editor *Editor, filename string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filename, data, 0644)
+	err = os.WriteFile(filename, data, 0644)
+	if err != nil {
+		return err
+	}
+	editor.MarkSaved()
+	return nil
 }
@@ -58,19 +58,19 @@ func TestUndo(t *testing.T) {
 
 	expected := []string{"0000", "11", "11", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.MarkUndo()
 	AsEdit(Insert([]rune("!")))(e)
 	expected = []string{"0000", "11!11", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 }
 
 func TestRedo(t *testing.T) {
@@ -101,26 +101,38 @@ func TestUndoAndRedo(t *testing.T) {
 
 	expected := []string{"0000", "11", "11", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.MarkUndo()
 	AsEdit(Insert([]rune("!")))(e)
 	expected = []string{"0000", "11!11", "2222", "3333"}
 
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Redo()
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Undo()
-	assert.Equal(t, ToRune(linesCopy), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(linesCopy), e.Buffer.(*BaseBuffer).Current.Value())
 
 	e.Redo()
-	assert.Equal(t, ToRune(expected), e.Buffer.Current.Value())
+	assert.Equal(t, ToRune(expected), e.Buffer.(*BaseBuffer).Current.Value())
+}
+
+func TestSetTabsize(t *testing.T) {
+	b := NewBuffer()
+	b.Current = b.Current.Insert(0, ToRune([]string{"\t\tab", "x"}))
+	e := &Editor{Buffer: b, Config: EditorConfig{Tabsize: 4}}
+	SetCursors(0,4,0,9, 1,1,1,2)(e)
+
+	SetTabsize(8)(e)
+	assert.Equal(t, 8, e.Config.Tabsize)
+	assert.Equal(t, Range{Location{0, 8}, Location{0, 17}}, e.Cursors[0].Range)
+	assert.Equal(t, Range{Location{1, 1}, Location{1, 2}}, e.Cursors[1].Range)
 }
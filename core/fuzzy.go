@@ -0,0 +1,100 @@
+package core
+
+import (
+	"sort"
+	"unicode"
+)
+
+// A candidate matching a fuzzy query (see FuzzyFilter)
+type FuzzyResult struct {
+	Index   int   // In the candidates
+	Score   int
+	Matched []int // Indices of the runes matching the query
+}
+
+// Scores how well the query matches the text, where the characters of the
+// query must appear in order, ignoring case. Consecutive characters and
+// ones at the start of words or path components score more, and characters
+// in between score less. The shortest part of the text matching is used.
+func FuzzyMatch(query, text string) (score int, matched []int, ok bool) {
+	q := []rune(query)
+	t := []rune(text)
+	if len(q) == 0 {
+		return 0, nil, true
+	}
+	equal := func(a, b rune) bool {
+		return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	// The first end of a match, and then the last start before it
+	end, i := -1, 0
+	for j := 0; j < len(t) && i < len(q); j++ {
+		if equal(q[i], t[j]) {
+			i++
+			end = j
+		}
+	}
+	if i < len(q) {
+		return 0, nil, false
+	}
+	start, i := end, len(q) - 1
+	for j := end; j >= 0 && i >= 0; j-- {
+		if equal(q[i], t[j]) {
+			i--
+			start = j
+		}
+	}
+
+	matched = make([]int, 0, len(q))
+	i = 0
+	for j := start; j <= end && i < len(q); j++ {
+		if !equal(q[i], t[j]) {
+			continue
+		}
+		score += 16
+		if len(matched) > 0 && matched[len(matched)-1] == j - 1 {
+			score += 8
+		}
+		score += boundaryBonus(t, j)
+		matched = append(matched, j)
+		i++
+	}
+	// Gaps in the match, and then the length of the text, make it worse
+	score -= end - start + 1 - len(q)
+	score -= len(t) / 16
+	return score, matched, true
+}
+
+func boundaryBonus(text []rune, index int) int {
+	if index == 0 {
+		return 10
+	}
+	previous := text[index-1]
+	switch {
+	case previous == '/':
+		return 12
+	case previous == '_' || previous == '-' || previous == '.' || unicode.IsSpace(previous):
+		return 8
+	case unicode.IsLower(previous) && unicode.IsUpper(text[index]):
+		return 8
+	}
+	return 0
+}
+
+// Returns the candidates matching the query, from the best to the worst.
+// Ties are broken by length, and then by the order of the candidates.
+func FuzzyFilter(query string, candidates []string) []FuzzyResult {
+	results := []FuzzyResult{}
+	for i, candidate := range candidates {
+		if score, matched, ok := FuzzyMatch(query, candidate); ok {
+			results = append(results, FuzzyResult{i, score, matched})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return len(candidates[results[i].Index]) < len(candidates[results[j].Index])
+	})
+	return results
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	_, matched, ok := FuzzyMatch("mgo", "core/main.go")
+	assert.True(t, ok)
+	assert.Equal(t, []int{5, 10, 11}, matched)
+
+	// The shortest part matching is used
+	_, matched, ok = FuzzyMatch("ab", "a a xab")
+	assert.True(t, ok)
+	assert.Equal(t, []int{5, 6}, matched)
+
+	_, _, ok = FuzzyMatch("ba", "abc")
+	assert.False(t, ok)
+
+	score, matched, ok := FuzzyMatch("", "abc")
+	assert.True(t, ok)
+	assert.Equal(t, 0, score)
+	assert.Nil(t, matched)
+
+	_, matched, ok = FuzzyMatch("ÑA", "xña")
+	assert.True(t, ok)
+	assert.Equal(t, []int{1, 2}, matched)
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	candidates := []string{
+		"core/editor_test.go",
+		"README.md",
+		"core/editor.go",
+		"advancedtui/renderer.go",
+		"modes.go",
+	}
+	indices := func(results []FuzzyResult) []int {
+		result := []int{}
+		for _, r := range results {
+			result = append(result, r.Index)
+		}
+		return result
+	}
+	assert.Equal(t, []int{2, 0, 3}, indices(FuzzyFilter("edgo", candidates)))
+	assert.Equal(t, []int{4}, indices(FuzzyFilter("mo", candidates)))
+	assert.Equal(t, []int{4, 1, 2, 0, 3}, indices(FuzzyFilter("", candidates)))
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The patterns of a .gitignore file, for the paths inside its directory
+type Gitignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	regex   *regexp.Regexp // Of the path relative to the directory
+	negated bool
+	dirOnly bool
+}
+
+// Parses the contents of a .gitignore. Invalid patterns are skipped.
+func ParseGitignore(contents string) *Gitignore {
+	gitignore := &Gitignore{}
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			pattern.negated = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pattern.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// Patterns with a slash are relative to the directory, the rest
+		// match the name at any depth
+		prefix := "(^|/)"
+		if strings.Contains(line, "/") {
+			prefix = "^"
+			line = strings.TrimPrefix(line, "/")
+		}
+		regex, err := regexp.Compile(prefix + globToRegex(line) + "$")
+		if err != nil {
+			continue
+		}
+		pattern.regex = regex
+		gitignore.patterns = append(gitignore.patterns, pattern)
+	}
+	return gitignore
+}
+
+// Loads the .gitignore in the directory, or returns nil if there is none
+func LoadGitignore(directory string) *Gitignore {
+	contents, err := os.ReadFile(filepath.Join(directory, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	return ParseGitignore(string(contents))
+}
+
+func globToRegex(glob string) string {
+	regex := strings.Builder{}
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			regex.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i + 3 == len(glob):
+			regex.WriteString("/.*")
+			i += 2
+		case glob[i] == '*':
+			regex.WriteString("[^/]*")
+		case glob[i] == '?':
+			regex.WriteString("[^/]")
+		case glob[i] == '\\' && i + 1 < len(glob):
+			i++
+			regex.WriteString(regexp.QuoteMeta(glob[i:i+1]))
+		case glob[i] == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				regex.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1:i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			regex.WriteString("[" + class + "]")
+			i += end
+		default:
+			regex.WriteString(regexp.QuoteMeta(glob[i:i+1]))
+		}
+	}
+	return regex.String()
+}
+
+// Whether the path, relative to the directory of the .gitignore and with
+// slashes, is ignored. The last pattern that matches decides, and ok is
+// false if none does.
+func (g *Gitignore) Ignored(relative string, isDir bool) (ignored bool, ok bool) {
+	for _, pattern := range g.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if pattern.regex.MatchString(relative) {
+			ignored, ok = !pattern.negated, true
+		}
+	}
+	return ignored, ok
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitignore(t *testing.T) {
+	gitignore := ParseGitignore(`# build output
+*.o
+/build
+docs/*.html
+!docs/index.html
+cache/
+**/tmp/**
+file?.txt
+\#hash
+`)
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+		ok      bool
+	}{
+		{"main.o", false, true, true},
+		{"src/util.o", false, true, true},
+		{"build", true, true, true},
+		{"src/build", true, false, false},
+		{"docs/page.html", false, true, true},
+		{"docs/index.html", false, false, true},
+		{"docs/sub/page.html", false, false, false},
+		{"cache", true, true, true},
+		{"cache", false, false, false},
+		{"src/cache", true, true, true},
+		{"a/tmp/b", false, true, true},
+		{"tmp/b/c", false, true, true},
+		{"file1.txt", false, true, true},
+		{"file10.txt", false, false, false},
+		{"#hash", false, true, true},
+		{"main.c", false, false, false},
+	}
+	for _, test := range tests {
+		ignored, ok := gitignore.Ignored(test.path, test.isDir)
+		assert.Equal(t, test.ignored, ignored, test.path)
+		assert.Equal(t, test.ok, ok, test.path)
+	}
+}
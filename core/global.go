@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+var ErrInvalidGlobal = errors.New("invalid global command, use /pattern/command")
+
+// Parses "/pattern/command", the arguments of Vim's :g, where the delimiter
+// can be any character
+func ParseGlobal(command string) (*regexp.Regexp, string, error) {
+	delimiter, size := utf8.DecodeRuneInString(command)
+	if size == 0 || delimiter == '\\' || delimiter == ' ' {
+		return nil, "", ErrInvalidGlobal
+	}
+	pattern, rest, _ := untilDelimiter(command[size:], delimiter)
+	if pattern == "" {
+		return nil, "", ErrInvalidGlobal
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	return regex, rest, nil
+}
+
+// Returns the rows in the range with lines matching the regex, or the ones
+// not matching it if invert is true
+func MatchingRows(editor *Editor, regex *regexp.Regexp, rangee LineRange, invert bool) []int {
+	rows := []int{}
+	for row := rangee.Start; row <= rangee.End; row++ {
+		if regex.MatchString(string(editor.Buffer.GetLine(row))) != invert {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// A buffer whose rows are marked, like the lines Vim's :g marks before
+// running its command. The marks follow the lines added and removed, and the
+// ones of removed lines are skipped. Restoring a version doesn't move them.
+type MarkedBuffer struct {
+	Buffer
+	rows    []int
+	removed []bool
+	next    int // The marks before it were already taken
+	offset  int // Added to the rows of the marks not taken
+}
+
+// The rows must be sorted
+func NewMarkedBuffer(buffer Buffer, rows []int) *MarkedBuffer {
+	return &MarkedBuffer{
+		Buffer:  buffer,
+		rows:    append([]int{}, rows...),
+		removed: make([]bool, len(rows)),
+	}
+}
+
+// Returns the row of the next mark, and false after the last one
+func (b *MarkedBuffer) Next() (row int, ok bool) {
+	for b.next < len(b.rows) {
+		b.next++
+		if !b.removed[b.next - 1] {
+			return b.rows[b.next - 1] + b.offset, true
+		}
+	}
+	return 0, false
+}
+
+func (b *MarkedBuffer) AddLine(index int, line []rune) {
+	b.Buffer.AddLine(index, line)
+	b.move(index, 1)
+}
+
+func (b *MarkedBuffer) RemoveLine(index int) {
+	b.Buffer.RemoveLine(index)
+	for i := b.firstFrom(index); i < len(b.rows) && b.rows[i] + b.offset == index; i++ {
+		b.removed[i] = true
+	}
+	b.move(index + 1, -1)
+}
+
+// The first mark not taken in the row or after it
+func (b *MarkedBuffer) firstFrom(row int) int {
+	return b.next + sort.Search(len(b.rows) - b.next, func(i int) bool {
+		return b.rows[b.next + i] + b.offset >= row
+	})
+}
+
+// Moves the marks not taken in the row or after it. The common case, where
+// all of them move, only changes the offset.
+func (b *MarkedBuffer) move(row int, by int) {
+	first := b.firstFrom(row)
+	if first == b.next {
+		b.offset += by
+		return
+	}
+	for i := first; i < len(b.rows); i++ {
+		b.rows[i] += by
+	}
+}
+
+var _ Buffer = (*MarkedBuffer)(nil) // Type Checking
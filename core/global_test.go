@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGlobal(t *testing.T) {
+	regex, command, err := ParseGlobal(`/a\/b/normal A;`)
+	assert.Nil(t, err)
+	assert.Equal(t, "a/b", regex.String())
+	assert.Equal(t, "normal A;", command)
+
+	regex, command, err = ParseGlobal(`#x#`)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", regex.String())
+	assert.Equal(t, "", command)
+
+	_, _, err = ParseGlobal(`//d`)
+	assert.Equal(t, ErrInvalidGlobal, err)
+	_, _, err = ParseGlobal(`/(/d`)
+	assert.NotNil(t, err)
+}
+
+func TestMatchingRows(t *testing.T) {
+	e := addressTestEditor()
+	regex, _, _ := ParseGlobal(`/o/`)
+	assert.Equal(t, []int{0, 1, 2, 4}, MatchingRows(e, regex, LineRange{0, 5}, false))
+	assert.Equal(t, []int{3, 5}, MatchingRows(e, regex, LineRange{0, 5}, true))
+	assert.Equal(t, []int{1, 2}, MatchingRows(e, regex, LineRange{1, 3}, false))
+}
+
+func TestMarkedBuffer(t *testing.T) {
+	e := substituteTestEditor("a", "b", "c", "d", "e")
+	b := NewMarkedBuffer(e.Buffer, []int{1, 3, 4})
+	row, ok := b.Next()
+	assert.Equal(t, 1, row)
+	assert.True(t, ok)
+
+	// Before the marks, all of them move
+	b.AddLine(0, []rune("x"))
+	// Between them, only the later ones
+	b.AddLine(5, []rune("y"))
+	b.RemoveLine(2)
+	assert.Equal(t, []string{"x", "a", "c", "d", "y", "e"}, linesOf(b))
+	row, ok = b.Next()
+	assert.Equal(t, 3, row)
+	assert.True(t, ok)
+
+	// Removed lines are skipped
+	b.RemoveLine(5)
+	_, ok = b.Next()
+	assert.False(t, ok)
+}
+
+func linesOf(buffer Buffer) []string {
+	lines := []string{}
+	for row := 0; row < buffer.GetLength(); row++ {
+		lines = append(lines, string(buffer.GetLine(row)))
+	}
+	return lines
+}
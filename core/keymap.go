@@ -0,0 +1,174 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Key sequences bound to values, like the keys of a mode and what they do.
+// The keys are runes, with the special ones (like the arrows) as negative.
+type Keymap[T any] struct {
+	bindings map[string]keyBinding[T]
+	longer   map[string]int // How many bindings are longer than each sequence and start with it
+}
+
+type keyBinding[T any] struct {
+	keys  []rune
+	value T
+}
+
+func NewKeymap[T any]() *Keymap[T] {
+	return &Keymap[T]{bindings: map[string]keyBinding[T]{}, longer: map[string]int{}}
+}
+
+// The keys as a map key. string(keys) would join the negative ones, which
+// are not valid runes.
+func keysString(keys []rune) string {
+	var result strings.Builder
+	for _, key := range keys {
+		result.WriteString(strconv.Itoa(int(key)))
+		result.WriteByte(' ')
+	}
+	return result.String()
+}
+
+// Binds the keys to the value, replacing the one they had
+func (k *Keymap[T]) Bind(keys []rune, value T) {
+	if _, ok := k.bindings[keysString(keys)]; !ok {
+		for i := 0; i < len(keys); i++ {
+			k.longer[keysString(keys[:i])]++
+		}
+	}
+	k.bindings[keysString(keys)] = keyBinding[T]{keys: append([]rune{}, keys...), value: value}
+}
+
+// Returns false if the keys were not bound
+func (k *Keymap[T]) Unbind(keys []rune) bool {
+	if _, ok := k.bindings[keysString(keys)]; !ok {
+		return false
+	}
+	delete(k.bindings, keysString(keys))
+	for i := 0; i < len(keys); i++ {
+		k.longer[keysString(keys[:i])]--
+		if k.longer[keysString(keys[:i])] == 0 {
+			delete(k.longer, keysString(keys[:i]))
+		}
+	}
+	return true
+}
+
+func (k *Keymap[T]) Get(keys []rune) (value T, ok bool) {
+	binding, ok := k.bindings[keysString(keys)]
+	return binding.value, ok
+}
+
+// Returns whether more keys after these could match a binding
+func (k *Keymap[T]) HasLonger(keys []rune) bool {
+	return k.longer[keysString(keys)] > 0
+}
+
+// Returns the bound sequences, sorted
+func (k *Keymap[T]) Keys() [][]rune {
+	keys := [][]rune{}
+	for _, binding := range k.bindings {
+		keys = append(keys, binding.keys)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		for n := 0; n < len(keys[i]) && n < len(keys[j]); n++ {
+			if keys[i][n] != keys[j][n] {
+				return keys[i][n] < keys[j][n]
+			}
+		}
+		return len(keys[i]) < len(keys[j])
+	})
+	return keys
+}
+
+// Parses keys written like in Vim's :map, such as "gg", "<C-r>" or
+// "<Leader>w". The names between < and > are the ones given (ignoring case),
+// <C-x> for the control keys, <Leader> for the leader keys, and <Nop> for no
+// keys. A < that doesn't start a name is itself.
+func ParseKeys(text string, names map[string]rune, leader []rune) ([]rune, error) {
+	keys := []rune{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		length := 0
+		if runes[i] == '<' {
+			length = nameLength(runes[i+1:])
+		}
+		if length == 0 {
+			keys = append(keys, runes[i])
+			continue
+		}
+		name := string(runes[i+1 : i+1+length])
+		named, ok := parseKeyName(name, names, leader)
+		if !ok {
+			return nil, fmt.Errorf("unknown key <%v>", name)
+		}
+		keys = append(keys, named...)
+		i += length + 1
+	}
+	return keys, nil
+}
+
+// Returns the length of the name at the start of the text, which must be
+// followed by >, or 0 if there is none
+func nameLength(text []rune) int {
+	for i, chr := range text {
+		switch {
+		case chr == '>':
+			return i
+		case i == 2 && text[1] == '-':
+			// The key of <C-x> can be anything
+		case !unicode.IsLetter(chr) && !unicode.IsDigit(chr) && chr != '-':
+			return 0
+		}
+	}
+	return 0
+}
+
+func parseKeyName(name string, names map[string]rune, leader []rune) ([]rune, bool) {
+	switch {
+	case strings.EqualFold(name, "leader"):
+		return leader, true
+	case strings.EqualFold(name, "nop"):
+		return []rune{}, true
+	case len(name) == 3 && strings.EqualFold(name[:2], "c-"):
+		chr := unicode.ToUpper(rune(name[2]))
+		if chr >= '@' && chr <= '_' {
+			return []rune{chr & 0x1f}, true
+		}
+	}
+	for other, key := range names {
+		if strings.EqualFold(name, other) {
+			return []rune{key}, true
+		}
+	}
+	return nil, false
+}
+
+// The opposite of ParseKeys. Keys with several names use the shortest.
+func FormatKeys(keys []rune, names map[string]rune) string {
+	var result strings.Builder
+	for _, key := range keys {
+		name := ""
+		for other, named := range names {
+			shorter := len(other) < len(name) || len(other) == len(name) && other < name
+			if named == key && (name == "" || shorter) {
+				name = other
+			}
+		}
+		switch {
+		case name != "":
+			result.WriteString("<" + name + ">")
+		case key >= 0 && key < ' ':
+			result.WriteString("<C-" + string(unicode.ToLower(key + '@')) + ">")
+		default:
+			result.WriteRune(key)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeyNames = map[string]rune{
+	"Esc":   27,
+	"CR":    '\n',
+	"Enter": '\n',
+	"Space": ' ',
+	"lt":    '<',
+	"Up":    -2,
+}
+
+func TestKeymap(t *testing.T) {
+	keymap := NewKeymap[string]()
+	keymap.Bind([]rune("gg"), "top")
+	keymap.Bind([]rune("g-"), "older")
+	keymap.Bind([]rune("G"), "bottom")
+	keymap.Bind([]rune{-2, -2}, "up twice")
+	keymap.Bind([]rune("gg"), "start")
+
+	value, ok := keymap.Get([]rune("gg"))
+	assert.True(t, ok)
+	assert.Equal(t, "start", value)
+	_, ok = keymap.Get([]rune("g"))
+	assert.False(t, ok)
+	assert.True(t, keymap.HasLonger([]rune("g")))
+	assert.True(t, keymap.HasLonger([]rune{}))
+	assert.False(t, keymap.HasLonger([]rune("gg")))
+	assert.False(t, keymap.HasLonger([]rune("G")))
+	// The special keys are negative, and don't match others
+	assert.True(t, keymap.HasLonger([]rune{-2}))
+	assert.False(t, keymap.HasLonger([]rune{-3}))
+
+	assert.Equal(t, [][]rune{{-2, -2}, []rune("G"), []rune("g-"), []rune("gg")}, keymap.Keys())
+
+	assert.True(t, keymap.Unbind([]rune("gg")))
+	assert.False(t, keymap.Unbind([]rune("gg")))
+	assert.True(t, keymap.HasLonger([]rune("g")))
+	assert.True(t, keymap.Unbind([]rune("g-")))
+	assert.False(t, keymap.HasLonger([]rune("g")))
+}
+
+func TestParseKeys(t *testing.T) {
+	leader := []rune(" ")
+	tests := []struct {
+		text string
+		keys []rune
+	}{
+		{"gg", []rune("gg")},
+		{"<Esc>:w<CR>", []rune("\x1b:w\n")},
+		{"<esc><ENTER>", []rune("\x1b\n")},
+		{"<C-r><c-W><C-[>", []rune{18, 23, 27}},
+		{"<Leader>w", []rune(" w")},
+		{"<Up>", []rune{-2}},
+		{"<Nop>", []rune{}},
+		{"a<b", []rune("a<b")},
+		{"<lt>b>", []rune("<b>")},
+		{"<>", []rune("<>")},
+		{"1 < 2", []rune("1 < 2")},
+	}
+	for _, test := range tests {
+		keys, err := ParseKeys(test.text, testKeyNames, leader)
+		assert.Nil(t, err, test.text)
+		assert.Equal(t, test.keys, keys, test.text)
+	}
+
+	_, err := ParseKeys("<Ecs>", testKeyNames, leader)
+	assert.Equal(t, "unknown key <Ecs>", err.Error())
+	_, err = ParseKeys("<C-1>", testKeyNames, leader)
+	assert.NotNil(t, err)
+}
+
+func TestFormatKeys(t *testing.T) {
+	assert.Equal(t, "<Esc>:w<CR>", FormatKeys([]rune("\x1b:w\n"), testKeyNames))
+	assert.Equal(t, "<C-r><C-\\><Up><Space>x<lt>", FormatKeys([]rune{18, 28, -2, ' ', 'x', '<'}, testKeyNames))
+	for _, text := range []string{"gg", "<C-w>v", "<Space>w<lt>b>"} {
+		keys, _ := ParseKeys(text, testKeyNames, nil)
+		assert.Equal(t, text, FormatKeys(keys, testKeyNames))
+	}
+}
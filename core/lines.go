@@ -0,0 +1,371 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Line oriented edits, used by the Ex commands with ranges
+
+func (r LineRange) Length() int {
+	return r.End - r.Start + 1
+}
+
+func getLines(editor *Editor, r LineRange) [][]rune {
+	lines := make([][]rune, 0, r.Length())
+	for row := r.Start; row <= r.End; row++ {
+		lines = append(lines, editor.Buffer.GetLine(row))
+	}
+	return lines
+}
+
+// Moves the cursors in the rows with the function
+func moveCursorRows(editor *Editor, newRow func(row int) int) {
+	for _, cursor := range editor.Cursors {
+		cursor.Start.Row = newRow(cursor.Start.Row)
+		cursor.End.Row = newRow(cursor.End.Row)
+	}
+}
+
+// Removes the rows. Cursors in them are moved to the start of the row after
+// them. The buffer is always left with at least one line.
+func DeleteLines(r LineRange) Edit {
+	return func(editor *Editor) {
+		for row := r.End; row >= r.Start; row-- {
+			editor.Buffer.RemoveLine(row)
+		}
+		if editor.Buffer.GetLength() == 0 {
+			editor.Buffer.AddLine(0, []rune{})
+		}
+		lastRow := editor.Buffer.GetLength() - 1
+		for _, cursor := range editor.Cursors {
+			if cursor.Start.Row > r.End {
+				cursor.Start.Row -= r.Length()
+				cursor.End.Row -= r.Length()
+			} else if cursor.End.Row >= r.Start {
+				row := r.Start
+				if row > lastRow {
+					row = lastRow
+				}
+				cursor.Range = Range{Location{row, 0}, Location{row, 1}}
+			}
+		}
+	}
+}
+
+// Saves the rows in the register of every cursor, ending with a newline so
+// they are pasted as whole lines
+func YankLines(r LineRange, register int) Edit {
+	return func(editor *Editor) {
+		yanked := []rune{}
+		for _, line := range getLines(editor, r) {
+			yanked = append(append(yanked, line...), '\n')
+		}
+		for _, cursor := range editor.Cursors {
+			cursor.Registers[register] = yanked
+		}
+	}
+}
+
+// Moves the rows below the row to, or to the top if it is -1. The cursors in
+// them move along.
+func MoveLines(r LineRange, to int) Edit {
+	return func(editor *Editor) {
+		if to >= r.Start - 1 && to <= r.End {
+			return
+		}
+		lines := getLines(editor, r)
+		for row := r.End; row >= r.Start; row-- {
+			editor.Buffer.RemoveLine(row)
+		}
+		first := to + 1
+		if to > r.End {
+			first -= r.Length()
+		}
+		for i, line := range lines {
+			editor.Buffer.AddLine(first + i, line)
+		}
+		moveCursorRows(editor, func(row int) int {
+			switch {
+			case row >= r.Start && row <= r.End:
+				return first + row - r.Start
+			case row > r.End && row <= to:
+				return row - r.Length()
+			case row > to && row < r.Start:
+				return row + r.Length()
+			}
+			return row
+		})
+	}
+}
+
+// Copies the rows below the row to, or to the top if it is -1
+func CopyLinesTo(r LineRange, to int) Edit {
+	return func(editor *Editor) {
+		for i, line := range getLines(editor, r) {
+			editor.Buffer.AddLine(to + 1 + i, line)
+		}
+		moveCursorRows(editor, func(row int) int {
+			if row > to {
+				return row + r.Length()
+			}
+			return row
+		})
+	}
+}
+
+// Joins the rows into one, removing the indentation of the joined lines and
+// separating them with a space. A range of a single row is joined with the
+// next one.
+func JoinLines(r LineRange) Edit {
+	return func(editor *Editor) {
+		if r.Start == r.End {
+			r.End++
+		}
+		if r.End >= editor.Buffer.GetLength() {
+			return
+		}
+		lines := getLines(editor, r)
+		joined := lines[0]
+		// Where each line starts in the joined one, and how much was removed
+		starts := make([]int, len(lines))
+		removed := make([]int, len(lines))
+		for i, line := range lines[1:] {
+			trimmed := trimLeftSpace(line)
+			if len(joined) > 0 && len(trimmed) > 0 && !unicode.IsSpace(joined[len(joined)-1]) {
+				joined = Join(joined, []rune{' '})
+			}
+			starts[i+1] = ColumnSpan(editor, joined)
+			removed[i+1] = ColumnSpan(editor, line[:len(line)-len(trimmed)])
+			joined = Join(joined, trimmed)
+		}
+
+		for row := r.End; row > r.Start; row-- {
+			editor.Buffer.RemoveLine(row)
+		}
+		editor.Buffer.ChangeLine(r.Start, joined)
+
+		moveLocation := func(location *Location) {
+			if location.Row > r.End {
+				location.Row -= r.Length() - 1
+			} else if location.Row > r.Start {
+				i := location.Row - r.Start
+				location.Row = r.Start
+				offset := location.Column - removed[i]
+				if offset < 0 {
+					offset = 0
+				}
+				location.Column = starts[i] + offset
+			}
+		}
+		for _, cursor := range editor.Cursors {
+			moveLocation(&cursor.Start)
+			moveLocation(&cursor.End)
+		}
+	}
+}
+
+func trimLeftSpace(line []rune) []rune {
+	for i, chr := range line {
+		if !unicode.IsSpace(chr) {
+			return line[i:]
+		}
+	}
+	return []rune{}
+}
+
+// Indents the rows by the levels, or dedents them if negative. A level of
+// indentation is a tab, or Tabsize spaces if expand is set (like InsertTab),
+// and a level of dedentation removes a tab or up to Tabsize spaces. Empty
+// lines are not indented.
+func IndentLines(r LineRange, levels int, expand bool) Edit {
+	return func(editor *Editor) {
+		level := "\t"
+		if expand && editor.Config.Tabsize > 0 {
+			level = strings.Repeat(" ", editor.Config.Tabsize)
+		}
+		for row := r.Start; row <= r.End; row++ {
+			line := editor.Buffer.GetLine(row)
+			var newLine []rune
+			if levels > 0 {
+				if len(line) == 0 {
+					continue
+				}
+				newLine = Join([]rune(strings.Repeat(level, levels)), line)
+			} else {
+				newLine = line
+				for i := 0; i < -levels; i++ {
+					newLine = dedent(editor, newLine)
+				}
+			}
+			editor.Buffer.ChangeLine(row, newLine)
+
+			shift := ColumnSpan(editor, newLine) - ColumnSpan(editor, line)
+			for _, cursor := range editor.Cursors {
+				if cursor.Start.Row == row {
+					cursor.Start.Column += shift
+					if cursor.Start.Column < 0 {
+						cursor.Start.Column = 0
+					}
+				}
+				if cursor.End.Row == row {
+					cursor.End.Column += shift
+					if cursor.End.Column < 1 {
+						cursor.End.Column = 1
+					}
+				}
+			}
+		}
+	}
+}
+
+func dedent(editor *Editor, line []rune) []rune {
+	if len(line) > 0 && line[0] == '\t' {
+		return line[1:]
+	}
+	i := 0
+	for i < len(line) && i < editor.Config.Tabsize && line[i] == ' ' {
+		i++
+	}
+	return line[i:]
+}
+
+// Replaces the rows with the lines, only changing the ones that differ (see
+// DiffLines), so cursors in the rest keep their place. Cursors after a change
+// are moved by the difference in length, and the ones inside it are kept in
+// the new lines.
+func ReplaceLines(r LineRange, lines [][]rune) Edit {
+	return func(editor *Editor) {
+		hunks := DiffLines(getLines(editor, r), lines)
+		for i := len(hunks) - 1; i >= 0; i-- {
+			hunk := hunks[i]
+			hunk.Start += r.Start
+			hunk.End += r.Start
+			replaceHunk(editor, hunk)
+		}
+		if editor.Buffer.GetLength() == 0 {
+			editor.Buffer.AddLine(0, []rune{})
+		}
+		removeOOBCursors(editor)
+	}
+}
+
+// Replaces the whole buffer with the lines, only changing the ones that
+// differ (see ReplaceLines)
+func SetLines(lines [][]rune) Edit {
+	return func(editor *Editor) {
+		ReplaceLines(LineRange{0, editor.Buffer.GetLength() - 1}, lines)(editor)
+	}
+}
+
+// The first lines of the hunk are changed in place, so the cursors in them
+// keep their columns, and the rest are removed or added
+func replaceHunk(editor *Editor, hunk Hunk) {
+	changed := hunk.End - hunk.Start
+	if len(hunk.Lines) < changed {
+		changed = len(hunk.Lines)
+	}
+	for i := 0; i < changed; i++ {
+		changeLine(editor, hunk.Start + i, hunk.Lines[i])
+	}
+	start := hunk.Start + changed
+	lines := hunk.Lines[changed:]
+
+	for row := hunk.End - 1; row >= start; row-- {
+		editor.Buffer.RemoveLine(row)
+	}
+	for i, line := range lines {
+		editor.Buffer.AddLine(start + i, line)
+	}
+	moveCursorRows(editor, func(row int) int { return rowAfterHunk(row, hunk) })
+}
+
+// Where a row goes when the hunk is applied. The rows after it are moved by
+// the difference in length, and the ones inside it stay, unless it has less
+// lines, in which case they go to its last one.
+func rowAfterHunk(row int, hunk Hunk) int {
+	if row >= hunk.End {
+		return row + len(hunk.Lines) - (hunk.End - hunk.Start)
+	}
+	lastRow := hunk.Start + len(hunk.Lines) - 1
+	if lastRow < hunk.Start {
+		lastRow = hunk.Start
+	}
+	if row >= hunk.Start && row > lastRow {
+		return lastRow
+	}
+	return row
+}
+
+// Moves the cursors, which were in the old lines, to the same lines in the
+// current ones, like ReplaceLines does, and then inside the buffer. It is
+// used for cursors kept apart from the editor while it changed.
+func FollowChanges(editor *Editor, cursors []*Cursor, old [][]rune) {
+	lastRow := editor.Buffer.GetLength() - 1
+	hunks := DiffLines(old, getLines(editor, LineRange{0, lastRow}))
+	// The end can be after the last character, in the newline
+	moveLocation := func(location *Location, newline int) {
+		for i := len(hunks) - 1; i >= 0; i-- {
+			location.Row = rowAfterHunk(location.Row, hunks[i])
+		}
+		if location.Row > lastRow {
+			location.Row = lastRow
+		}
+		if span := ColumnSpan(editor, editor.Buffer.GetLine(location.Row)) + newline; location.Column > span {
+			location.Column = span
+		}
+	}
+	for _, cursor := range cursors {
+		moveLocation(&cursor.Start, 0)
+		moveLocation(&cursor.End, 1)
+		if !comesFirst(cursor.Start, cursor.End) {
+			cursor.End = Location{Row: cursor.Start.Row, Column: cursor.Start.Column + 1}
+		}
+	}
+}
+
+// Changes the line, keeping the cursors in the same characters, using a
+// diff of them. The cursors in characters that were removed are moved to the
+// start of what replaced them.
+func changeLine(editor *Editor, row int, line []rune) {
+	old := editor.Buffer.GetLine(row)
+	editor.Buffer.ChangeLine(row, line)
+
+	// Where each character of old ends up in line, with the end of the line
+	// after them
+	newIndices := make([]int, len(old) + 1)
+	matches := commonSubsequence(runeIds(old), runeIds(line))
+	next := 0 // After the last character that is kept
+	for i, j := 0, 0; i < len(old); i++ {
+		if j < len(matches) && matches[j][0] == i {
+			newIndices[i] = matches[j][1]
+			next = matches[j][1] + 1
+			j++
+		} else {
+			newIndices[i] = next
+		}
+	}
+	newIndices[len(old)] = next
+
+	moveLocation := func(location *Location) {
+		if location.Row == row {
+			index := ColumnToIndex(editor, old, location.Column)
+			location.Column = ColumnSpan(editor, line[:newIndices[index]])
+		}
+	}
+	for _, cursor := range editor.Cursors {
+		moveLocation(&cursor.Start)
+		moveLocation(&cursor.End)
+		if cursor.Start == cursor.End {
+			cursor.End.Column++
+		}
+	}
+}
+
+func runeIds(line []rune) []int {
+	ids := make([]int, len(line))
+	for i, chr := range line {
+		ids[i] = int(chr)
+	}
+	return ids
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteLines(t *testing.T) {
+	e := addressTestEditor()
+	SetCursors(5,1,5,2)(e)
+	DeleteLines(LineRange{1, 3})(e)
+	assert.Equal(t, ToRune([]string{"zero", "four", "five"}), lines(e))
+	assert.Equal(t, []Range{
+		{Location{1, 0}, Location{1, 1}},
+		{Location{2, 1}, Location{2, 2}},
+	}, cursorRanges(e))
+
+	DeleteLines(LineRange{0, 2})(e)
+	assert.Equal(t, ToRune([]string{""}), lines(e))
+}
+
+func TestYankLines(t *testing.T) {
+	e := addressTestEditor()
+	YankLines(LineRange{0, 1}, 2)(e)
+	assert.Equal(t, []rune("zero\none\n"), e.Cursors[0].Registers[2])
+}
+
+func TestMoveLines(t *testing.T) {
+	e := addressTestEditor()
+	MoveLines(LineRange{1, 2}, 4)(e)
+	assert.Equal(t, ToRune([]string{"zero", "three", "four", "one", "two", "five"}), lines(e))
+	assert.Equal(t, 4, e.Cursors[0].Start.Row)
+
+	MoveLines(LineRange{3, 4}, -1)(e)
+	assert.Equal(t, ToRune([]string{"one", "two", "zero", "three", "four", "five"}), lines(e))
+	assert.Equal(t, 1, e.Cursors[0].Start.Row)
+}
+
+func TestCopyLinesTo(t *testing.T) {
+	e := addressTestEditor()
+	CopyLinesTo(LineRange{4, 5}, 0)(e)
+	assert.Equal(t, ToRune([]string{"zero", "four", "five", "one", "two", "three", "four", "five"}), lines(e))
+	assert.Equal(t, 4, e.Cursors[0].Start.Row)
+}
+
+func TestJoinLines(t *testing.T) {
+	e := substituteTestEditor("a", "  b ", "", "\tc", "d")
+	e.Config.Tabsize = 4
+	e.Cursors = nil
+	SetCursors(3,4,3,5, 4,0,4,1)(e)
+	JoinLines(LineRange{0, 3})(e)
+	assert.Equal(t, ToRune([]string{"a b c", "d"}), lines(e))
+	assert.Equal(t, []Range{
+		{Location{0, 4}, Location{0, 5}},
+		{Location{1, 0}, Location{1, 1}},
+	}, cursorRanges(e))
+
+	JoinLines(LineRange{0, 0})(e)
+	assert.Equal(t, ToRune([]string{"a b c d"}), lines(e))
+}
+
+func TestIndentLines(t *testing.T) {
+	e := substituteTestEditor("a", "", "      b")
+	e.Config.Tabsize = 4
+	IndentLines(LineRange{0, 1}, 2, false)(e)
+	assert.Equal(t, ToRune([]string{"\t\ta", "", "      b"}), lines(e))
+	assert.Equal(t, Range{Location{0, 8}, Location{0, 9}}, e.Cursors[0].Range)
+
+	IndentLines(LineRange{0, 2}, -1, false)(e)
+	assert.Equal(t, ToRune([]string{"\ta", "", "  b"}), lines(e))
+
+	// With expand, like InsertTab
+	IndentLines(LineRange{0, 2}, 1, true)(e)
+	assert.Equal(t, ToRune([]string{"    \ta", "", "      b"}), lines(e))
+	IndentLines(LineRange{0, 2}, -2, true)(e)
+	assert.Equal(t, ToRune([]string{"a", "", "b"}), lines(e))
+}
+
+func TestReplaceLines(t *testing.T) {
+	e := addressTestEditor()
+	SetCursors(5,0,5,1)(e)
+	ReplaceLines(LineRange{1, 3}, ToRune([]string{"x"}))(e)
+	assert.Equal(t, ToRune([]string{"zero", "x", "four", "five"}), lines(e))
+	assert.Equal(t, []Range{
+		{Location{1, 0}, Location{1, 1}},
+		{Location{3, 0}, Location{3, 1}},
+	}, cursorRanges(e))
+}
+
+func TestFollowChanges(t *testing.T) {
+	e := addressTestEditor()
+	old := CopyLines(lines(e))
+	kept := []*Cursor{
+		{Range: Range{Location{0, 1}, Location{0, 2}}},
+		{Range: Range{Location{2, 0}, Location{2, 3}}},
+		{Range: Range{Location{5, 2}, Location{5, 3}}},
+	}
+	// A line is added before "two", and "four" and "five" are removed
+	ReplaceLines(LineRange{0, 5}, ToRune([]string{"zero", "new", "one", "two", "three"}))(e)
+	FollowChanges(e, kept, old)
+	assert.Equal(t, []Range{
+		{Location{0, 1}, Location{0, 2}},
+		{Location{3, 0}, Location{3, 3}},
+		{Location{4, 2}, Location{4, 3}},
+	}, []Range{kept[0].Range, kept[1].Range, kept[2].Range})
+
+	// Columns past the end of shorter lines are moved to it
+	old = CopyLines(lines(e))
+	ReplaceLines(LineRange{4, 4}, ToRune([]string{"t"}))(e)
+	FollowChanges(e, kept, old)
+	assert.Equal(t, Range{Location{4, 1}, Location{4, 2}}, kept[2].Range)
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type OptionType int
+
+const (
+	BoolOption OptionType = iota
+	IntOption
+	StringOption
+	EnumOption // A string from a fixed list
+)
+
+// Where the value of an option is kept
+type OptionScope int
+
+const (
+	GlobalScope OptionScope = iota
+	BufferScope
+	WindowScope
+)
+
+// A setting that can be changed with :set
+type Option struct {
+	Name     string
+	Type     OptionType
+	Scope    OptionScope
+	Default  any                   // A bool, int or string, matching the type
+	Values   []string              // The ones allowed, for enums
+	Validate func(value any) error // Can be nil
+	OnChange func(value any)       // Called after it is set, can be nil
+}
+
+// The values of the options set in a scope. The rest have their default.
+type OptionValues map[string]any
+
+// The options that can be set, by name
+type Options struct {
+	defined map[string]*Option
+}
+
+func NewOptions() *Options {
+	return &Options{defined: map[string]*Option{}}
+}
+
+// Adds the option, replacing any other with the same name
+func (o *Options) Define(option Option) {
+	o.defined[option.Name] = &option
+}
+
+func (o *Options) Lookup(name string) (*Option, bool) {
+	option, ok := o.defined[name]
+	return option, ok
+}
+
+// Returns the names of the options, sorted
+func (o *Options) Names() []string {
+	names := []string{}
+	for name := range o.defined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the value of the option in the scope, or its default. It is nil
+// for options that don't exist.
+func (o *Options) Get(values OptionValues, name string) any {
+	option, ok := o.defined[name]
+	if !ok {
+		return nil
+	}
+	if value, ok := values[name]; ok {
+		return value
+	}
+	return option.Default
+}
+
+// Like Get, but false if the option is not a boolean
+func (o *Options) Bool(values OptionValues, name string) bool {
+	value, _ := o.Get(values, name).(bool)
+	return value
+}
+
+// Like Get, but zero if the option is not an integer
+func (o *Options) Int(values OptionValues, name string) int {
+	value, _ := o.Get(values, name).(int)
+	return value
+}
+
+// Like Get, but empty if the option is not a string or enum
+func (o *Options) String(values OptionValues, name string) string {
+	value, _ := o.Get(values, name).(string)
+	return value
+}
+
+// Runs an argument of :set, where scope returns the values where the option
+// is kept. The value is read from the first ones, and set in all of them.
+// The forms are:
+//   name        enables a boolean, or shows the value of the rest
+//   noname      disables a boolean
+//   invname     toggles a boolean, as does name!
+//   name?       shows the value
+//   name&       resets to the default
+//   name=value  sets the value of the rest
+// The output is the value, if it was shown.
+func (o *Options) Set(argument string, scope func(OptionScope) []OptionValues) (output string, err error) {
+	name, value, hasValue := strings.Cut(argument, "=")
+	option, ok := o.defined[name]
+	var newValue any
+	switch {
+	case hasValue && ok:
+		newValue, err = parseOptionValue(option, value)
+		if err != nil {
+			return "", err
+		}
+	case hasValue:
+		return "", fmt.Errorf("unknown option %q", name)
+	case ok && option.Type == BoolOption:
+		newValue = true
+	case ok:
+		return option.Format(o.Get(scope(option.Scope)[0], name)), nil
+	case strings.HasSuffix(name, "?"):
+		option, ok = o.defined[name[:len(name)-1]]
+		if !ok {
+			return "", fmt.Errorf("unknown option %q", name[:len(name)-1])
+		}
+		return option.Format(o.Get(scope(option.Scope)[0], option.Name)), nil
+	case strings.HasSuffix(name, "&"):
+		option, ok = o.defined[name[:len(name)-1]]
+		if !ok {
+			return "", fmt.Errorf("unknown option %q", name[:len(name)-1])
+		}
+		newValue = option.Default
+	default:
+		option, newValue, err = o.setBool(name, scope)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if option.Validate != nil {
+		if err := option.Validate(newValue); err != nil {
+			return "", fmt.Errorf("invalid %v: %w", option.Name, err)
+		}
+	}
+	for _, values := range scope(option.Scope) {
+		values[option.Name] = newValue
+	}
+	if option.OnChange != nil {
+		option.OnChange(newValue)
+	}
+	return "", nil
+}
+
+// Handles noname, invname and name!, returning the new value
+func (o *Options) setBool(name string, scope func(OptionScope) []OptionValues) (*Option, any, error) {
+	var option *Option
+	var value func(current bool) bool
+	if strings.HasPrefix(name, "no") {
+		option = o.defined[name[2:]]
+		value = func(bool) bool { return false }
+	}
+	if option == nil && strings.HasPrefix(name, "inv") {
+		option = o.defined[name[3:]]
+		value = func(current bool) bool { return !current }
+	}
+	if option == nil && strings.HasSuffix(name, "!") {
+		option = o.defined[name[:len(name)-1]]
+		value = func(current bool) bool { return !current }
+	}
+	if option == nil {
+		return nil, nil, fmt.Errorf("unknown option %q", name)
+	}
+	if option.Type != BoolOption {
+		return nil, nil, fmt.Errorf("%v is not a boolean, use %v=value", option.Name, option.Name)
+	}
+	current, _ := o.Get(scope(option.Scope)[0], option.Name).(bool)
+	return option, value(current), nil
+}
+
+func parseOptionValue(option *Option, value string) (any, error) {
+	switch option.Type {
+	case BoolOption:
+		return nil, fmt.Errorf("%v is a boolean, use %v or no%v", option.Name, option.Name, option.Name)
+	case IntOption:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v must be a number", option.Name)
+		}
+		return number, nil
+	case EnumOption:
+		for _, allowed := range option.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("%v must be one of %v", option.Name, strings.Join(option.Values, ", "))
+	}
+	return value, nil
+}
+
+// Returns the option with the value as :set takes it, like "tabsize=4" or
+// "noexpandtab"
+func (option *Option) Format(value any) string {
+	if option.Type == BoolOption {
+		if value == true {
+			return option.Name
+		}
+		return "no" + option.Name
+	}
+	return fmt.Sprintf("%v=%v", option.Name, value)
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptions() (*Options, map[OptionScope]OptionValues, *[]any) {
+	changes := &[]any{}
+	options := NewOptions()
+	options.Define(Option{
+		Name: "tabsize",
+		Type: IntOption,
+		Scope: BufferScope,
+		Default: 4,
+		Validate: func(value any) error {
+			if value.(int) < 1 {
+				return errors.New("it must be positive")
+			}
+			return nil
+		},
+		OnChange: func(value any) { *changes = append(*changes, value) },
+	})
+	options.Define(Option{Name: "expandtab", Type: BoolOption, Scope: BufferScope, Default: false})
+	options.Define(Option{Name: "number", Type: BoolOption, Scope: WindowScope, Default: true})
+	options.Define(Option{
+		Name: "mode",
+		Type: EnumOption,
+		Scope: GlobalScope,
+		Default: "a",
+		Values: []string{"a", "b"},
+	})
+	options.Define(Option{Name: "name", Type: StringOption, Scope: GlobalScope, Default: ""})
+	scopes := map[OptionScope]OptionValues{
+		GlobalScope: {},
+		BufferScope: {},
+		WindowScope: {},
+	}
+	return options, scopes, changes
+}
+
+func TestOptionsSet(t *testing.T) {
+	options, scopes, changes := testOptions()
+	scope := func(s OptionScope) []OptionValues { return []OptionValues{scopes[s]} }
+	set := func(argument string) string {
+		output, err := options.Set(argument, scope)
+		assert.Nil(t, err, argument)
+		return output
+	}
+
+	assert.Equal(t, "tabsize=4", set("tabsize"))
+	assert.Equal(t, "", set("tabsize=8"))
+	assert.Equal(t, 8, options.Int(scopes[BufferScope], "tabsize"))
+	assert.Equal(t, 4, options.Int(scopes[GlobalScope], "tabsize"))
+	assert.Equal(t, "tabsize=8", set("tabsize?"))
+	set("tabsize&")
+	assert.Equal(t, 4, options.Int(scopes[BufferScope], "tabsize"))
+	assert.Equal(t, []any{8, 4}, *changes)
+
+	assert.Equal(t, "noexpandtab", set("expandtab?"))
+	set("expandtab")
+	assert.True(t, options.Bool(scopes[BufferScope], "expandtab"))
+	set("expandtab!")
+	assert.False(t, options.Bool(scopes[BufferScope], "expandtab"))
+	set("invexpandtab")
+	assert.True(t, options.Bool(scopes[BufferScope], "expandtab"))
+	set("nonumber")
+	assert.False(t, options.Bool(scopes[WindowScope], "number"))
+	assert.Equal(t, "nonumber", set("number?"))
+
+	set("mode=b")
+	set("name=a b")
+	assert.Equal(t, "b", options.String(scopes[GlobalScope], "mode"))
+	assert.Equal(t, "a b", options.String(scopes[GlobalScope], "name"))
+	assert.Equal(t, "name=a b", set("name"))
+
+	// Read from the first, and written to both
+	defaults := OptionValues{}
+	both := func(s OptionScope) []OptionValues { return []OptionValues{scopes[s], defaults} }
+	_, err := options.Set("expandtab!", both)
+	assert.Nil(t, err)
+	assert.Equal(t, OptionValues{"expandtab": false}, defaults)
+	assert.False(t, options.Bool(scopes[BufferScope], "expandtab"))
+}
+
+func TestOptionsSetErrors(t *testing.T) {
+	options, scopes, changes := testOptions()
+	scope := func(s OptionScope) []OptionValues { return []OptionValues{scopes[s]} }
+	for _, argument := range []string{
+		"tabsize=x",
+		"tabsize=0",
+		"notabsize",
+		"expandtab=true",
+		"mode=c",
+		"missing",
+		"nomissing",
+		"missing=1",
+		"missing?",
+	} {
+		_, err := options.Set(argument, scope)
+		assert.NotNil(t, err, argument)
+	}
+	assert.Equal(t, OptionValues{}, scopes[BufferScope])
+	assert.Equal(t, []any{}, *changes)
+
+	_, err := options.Set("tabsize=0", scope)
+	assert.Equal(t, "invalid tabsize: it must be positive", err.Error())
+	assert.Nil(t, options.Get(scopes[GlobalScope], "missing"))
+	assert.Equal(t, 0, options.Int(scopes[GlobalScope], "expandtab"))
+	assert.Equal(t, []string{"expandtab", "mode", "name", "number", "tabsize"}, options.Names())
+}
@@ -0,0 +1,130 @@
+package core
+
+import "unsafe"
+
+// Rough amount of bytes used by a revision besides its lines, like the rope
+// nodes and map entries.
+const revisionOverhead = 128
+
+// Removes the oldest revisions until the history is within the limits of the
+// config. The current revision is never removed, and the children of removed
+// revisions are attached to their grandparent, so undoing skips the removed
+// state.
+func (e *Editor) Prune() {
+	config := e.Config
+	h := &e.History
+	now := e.Versions.now()
+	for {
+		oldest := e.oldestRemovable()
+		if oldest == nil {
+			return
+		}
+		tooMany := config.MaxVersions > 0 && len(h.Revisions) > config.MaxVersions
+		tooOld := config.MaxVersionAge > 0 && now.Sub(oldest.Time) > config.MaxVersionAge
+		tooBig := config.MaxVersionBytes > 0 && e.HistorySize() > config.MaxVersionBytes
+		if !tooMany && !tooOld && !tooBig {
+			return
+		}
+		e.removeRevision(oldest)
+	}
+}
+
+// A root with many children can't be removed without splitting the tree
+func (e *Editor) oldestRemovable() *Revision {
+	for _, revision := range e.History.Revisions {
+		if revision != e.History.Current &&
+			(revision.Parent != nil || len(revision.Children) == 1) {
+				return revision
+		}
+	}
+	return nil
+}
+
+func (e *Editor) removeRevision(revision *Revision) {
+	h := &e.History
+	parent := revision.Parent
+	// The children are now compared with the grandparent
+	e.uncountSize(revision)
+	for _, child := range revision.Children {
+		e.uncountSize(child)
+		child.Parent = parent
+		child.size = 0
+		e.countSize(child)
+	}
+
+	if parent == nil {
+		h.Root = revision.Children[0]
+	} else {
+		redoChild := parent.Children[parent.redo]
+		if redoChild == revision && len(revision.Children) > 0 {
+			redoChild = revision.Children[revision.redo]
+		}
+		children := []*Revision{}
+		for _, child := range parent.Children {
+			if child == revision {
+				children = append(children, revision.Children...)
+			} else {
+				children = append(children, child)
+			}
+		}
+		parent.Children = children
+		parent.redo = 0
+		for i, child := range children {
+			if child == redoChild {
+				parent.redo = i
+			}
+		}
+	}
+
+	h.Revisions = filter(h.Revisions, func(r *Revision) bool {
+		return r != revision
+	})
+	e.Buffer.DeleteVersion(revision.Version)
+	delete(e.CursorsVersions, revision.Version)
+}
+
+// Estimated amount of bytes used by the undo history. As the versions share
+// their unchanged lines, only the lines that differ from the parent are
+// counted for each revision. The whole history is only measured the first
+// time, like after loading an undo file, and then the total is updated as
+// revisions are added and removed.
+func (e *Editor) HistorySize() int {
+	h := &e.History
+	if !h.sized {
+		h.size = 0
+		for _, revision := range h.Revisions {
+			h.size += e.revisionSize(revision)
+		}
+		h.sized = true
+	}
+	return h.size
+}
+
+func (e *Editor) countSize(revision *Revision) {
+	if e.History.sized {
+		e.History.size += e.revisionSize(revision)
+	}
+}
+
+func (e *Editor) uncountSize(revision *Revision) {
+	if e.History.sized {
+		e.History.size -= e.revisionSize(revision)
+	}
+}
+
+func (e *Editor) revisionSize(revision *Revision) int {
+	if revision.size > 0 {
+		return revision.size
+	}
+	lines := e.Buffer.GetVersion(revision.Version)
+	prefix, suffix := 0, 0
+	if revision.Parent != nil {
+		prefix, suffix = commonLines(e.Buffer.GetVersion(revision.Parent.Version), lines)
+	}
+	revision.size = revisionOverhead
+	revision.size += len(e.CursorsVersions[revision.Version]) * int(unsafe.Sizeof(Cursor{}))
+	for _, line := range lines[prefix:len(lines)-suffix] {
+		revision.size += int(unsafe.Sizeof(line)) + len(line) * int(unsafe.Sizeof(rune(0)))
+	}
+	return revision.size
+}
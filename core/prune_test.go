@@ -0,0 +1,133 @@
+package core
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneMaxVersions(t *testing.T) {
+	e := undoTestEditor()
+	e.Config.MaxVersions = 3
+
+	states := [][][]rune{}
+	for _, chr := range "abcde" {
+		e.MarkUndo()
+		states = append(states, lines(e))
+		AsEdit(Insert([]rune{chr}))(e)
+	}
+	e.Undo()
+	assert.Equal(t, 3, len(e.History.Revisions))
+	assert.Equal(t, states[4], lines(e))
+
+	e.Undo()
+	assert.Equal(t, states[3], lines(e))
+	e.Undo()
+	e.Undo()
+	assert.Equal(t, states[3], lines(e))
+
+	for i := 1; i < len(e.History.Revisions); i++ {
+		assert.Less(t, e.History.Revisions[i-1].Version, e.History.Revisions[i].Version)
+	}
+	assert.Equal(t, 3, len(e.Buffer.(*BaseBuffer).Versions))
+	assert.Equal(t, 3, len(e.CursorsVersions))
+}
+
+func TestPruneKeepsBranches(t *testing.T) {
+	e := undoTestEditor()
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b")))(e)
+	branchB := lines(e)
+	e.Undo()
+	e.MarkUndo()
+	AsEdit(Insert([]rune("c")))(e)
+	branchC := lines(e)
+	e.Undo()
+	e.Undo()
+
+	// Root -> a -> (b, c), the root is removed
+	e.Config.MaxVersions = 3
+	e.Prune()
+	assert.Equal(t, 3, len(e.History.Revisions))
+	assert.Nil(t, e.History.Root.Parent)
+	assert.Equal(t, 2, len(e.History.Root.Children))
+
+	e.Redo()
+	assert.Equal(t, branchC, lines(e))
+	e.SwitchBranch(1)
+	assert.Equal(t, branchB, lines(e))
+
+	// The current revision is kept
+	e.Config.MaxVersions = 1
+	e.Prune()
+	assert.Equal(t, 1, len(e.History.Revisions))
+	assert.Equal(t, branchB, lines(e))
+	e.Undo()
+	assert.Equal(t, branchB, lines(e))
+}
+
+func TestPruneMaxAge(t *testing.T) {
+	e := undoTestEditor()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.Versions.Now = func() time.Time { return now }
+	for _, chr := range "abc" {
+		e.MarkUndo()
+		AsEdit(Insert([]rune{chr}))(e)
+		now = now.Add(time.Minute)
+	}
+	e.Undo()
+
+	// The age is measured with the clock of the history
+	e.Config.MaxVersionAge = 90 * time.Second
+	e.Prune()
+	assert.Equal(t, 2, len(e.History.Revisions))
+}
+
+func TestPruneMaxBytes(t *testing.T) {
+	e := undoTestEditor()
+	for _, chr := range "abc" {
+		e.MarkUndo()
+		AsEdit(Insert([]rune{chr}))(e)
+	}
+	e.Undo()
+	size := e.HistorySize()
+	assert.Greater(t, size, 0)
+
+	e.Config.MaxVersionBytes = size - 1
+	e.Prune()
+	assert.Less(t, e.HistorySize(), size)
+	assert.Equal(t, 3, len(e.History.Revisions))
+}
+
+func TestHistorySizeKeptUpToDate(t *testing.T) {
+	e := undoTestEditor()
+	e.Config.MaxVersionBytes = 16000
+	for i := 0; i < 20; i++ {
+		e.MarkUndo()
+		AsEdit(Insert([]rune("ab\nc")))(e)
+		if i % 5 == 0 {
+			e.Undo()
+			SetCursors(0, 1, 0, 2)(e)
+		}
+	}
+	e.MarkUndo()
+	kept := e.HistorySize()
+	assert.LessOrEqual(t, kept, 16000)
+	assert.Greater(t, len(e.History.Revisions), 1)
+	assert.Less(t, len(e.History.Revisions), 21)
+
+	e.History.sized = false
+	assert.Equal(t, e.HistorySize(), kept)
+}
+
+func TestRollbackDeletesCheckpoint(t *testing.T) {
+	e := undoTestEditor()
+	e.MarkUndo()
+	checkpoint := e.Checkpoint()
+	e.Rollback(checkpoint)
+	assert.Equal(t, 1, len(e.Buffer.(*BaseBuffer).Versions))
+	assert.Equal(t, 1, len(e.CursorsVersions))
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// A location in a file, from the output of a compiler or grep
+type QuickfixEntry struct {
+	Filename string
+	Row      int // Zero-indexed
+	Column   int // Zero-indexed, in characters
+	Message  string
+}
+
+// Patterns for the lines of the output of a program, with the groups file,
+// line, col and message. Only Entry is required.
+type ErrorFormat struct {
+	Entry   *regexp.Regexp // A location, with the file if File is nil
+	File    *regexp.Regexp // Sets the file of the following entries
+	Message *regexp.Regexp // Sets the message of the next entry without one
+}
+
+var ErrorFormats = map[string]ErrorFormat{
+	// file:line:col: message, or file:line: message. Newer versions of gcc
+	// count tabs as 8 columns, unless -fdiagnostics-column-unit=byte is used.
+	"gcc": {
+		Entry: regexp.MustCompile(`^(?P<file>[^:\s][^:]*):(?P<line>\d+):(?:(?P<col>\d+):)? (?P<message>.*)$`),
+	},
+	// Like gcc, but go vet adds "vet: " before some
+	"go": {
+		Entry: regexp.MustCompile(`^(?:vet: )?(?P<file>[^:\s][^:]*\.go):(?P<line>\d+)(?::(?P<col>\d+))?: (?P<message>.*)$`),
+	},
+	// The message, followed by " --> file:line:col"
+	"rustc": {
+		Entry:   regexp.MustCompile(`^\s*--> (?P<file>[^:]+):(?P<line>\d+):(?P<col>\d+)$`),
+		Message: regexp.MustCompile(`^(?P<message>(?:error|warning)(?:\[\w+\])?: .*)$`),
+	},
+	// The absolute path of the file, followed by "  line:col  error  message"
+	"eslint": {
+		Entry: regexp.MustCompile(`^\s+(?P<line>\d+):(?P<col>\d+)\s+(?P<message>(?:error|warning)\s.*)$`),
+		File:  regexp.MustCompile(`^(?P<file>/\S*)$`),
+	},
+	// grep -n, where the match can have colons
+	"grep": {
+		Entry: regexp.MustCompile(`^(?P<file>[^:]+):(?P<line>\d+):(?P<message>.*)$`),
+	},
+}
+
+// The formats tried by "auto", which excludes grep, as it matches too much
+var autoErrorFormats = []string{"gcc", "go", "rustc", "eslint"}
+
+// Returns the formats for the name, which can be "auto" for most of them.
+// The second value is false if there is no such format.
+func GetErrorFormats(name string) ([]ErrorFormat, bool) {
+	if name == "auto" {
+		formats := []ErrorFormat{}
+		for _, name := range autoErrorFormats {
+			formats = append(formats, ErrorFormats[name])
+		}
+		return formats, true
+	}
+	format, ok := ErrorFormats[name]
+	return []ErrorFormat{format}, ok
+}
+
+// The names of the formats, sorted
+func ErrorFormatNames() []string {
+	names := []string{"auto"}
+	for name := range ErrorFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Returns the entries in the lines of output, trying the formats in order.
+// Lines that don't match any are skipped.
+func ParseErrors(output []string, formats []ErrorFormat) []QuickfixEntry {
+	entries := []QuickfixEntry{}
+	file, message := "", ""
+lines:
+	for _, line := range output {
+		for _, format := range formats {
+			if groups := namedGroups(format.Entry, line); groups != nil {
+				entry := QuickfixEntry{Filename: groups["file"], Message: groups["message"]}
+				if entry.Filename == "" {
+					entry.Filename = file
+				}
+				if entry.Message == "" {
+					entry.Message = message
+				}
+				message = ""
+				entry.Row, _ = strconv.Atoi(groups["line"])
+				entry.Row--
+				if column, err := strconv.Atoi(groups["col"]); err == nil {
+					entry.Column = column - 1
+				}
+				if entry.Filename != "" && entry.Row >= 0 && entry.Column >= 0 {
+					entries = append(entries, entry)
+				}
+				continue lines
+			}
+			if groups := namedGroups(format.File, line); groups != nil {
+				file = groups["file"]
+				continue lines
+			}
+			if groups := namedGroups(format.Message, line); groups != nil {
+				message = groups["message"]
+				continue lines
+			}
+		}
+	}
+	return entries
+}
+
+// The named groups of the match of the regex, or nil if it doesn't match or
+// there is no regex
+func namedGroups(regex *regexp.Regexp, line string) map[string]string {
+	if regex == nil {
+		return nil
+	}
+	match := regex.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	groups := map[string]string{}
+	for i, name := range regex.SubexpNames() {
+		if name != "" {
+			groups[name] = match[i]
+		}
+	}
+	return groups
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseWith(name string, output string) []QuickfixEntry {
+	formats, _ := GetErrorFormats(name)
+	return ParseErrors(strings.Split(output, "\n"), formats)
+}
+
+func TestParseErrorsGcc(t *testing.T) {
+	output := `main.c: In function 'main':
+main.c:3:5: error: 'x' undeclared (first use in this function)
+    3 |     x = 1;
+      |     ^
+src/util.h:10: warning: no newline at end of file
+make: *** [Makefile:2: all] Error 1`
+	assert.Equal(t, []QuickfixEntry{
+		{"main.c", 2, 4, "error: 'x' undeclared (first use in this function)"},
+		{"src/util.h", 9, 0, "warning: no newline at end of file"},
+	}, parseWith("gcc", output))
+}
+
+func TestParseErrorsGo(t *testing.T) {
+	output := `# example.com/m
+./main.go:8:2: undefined: fmt
+vet: ./util.go:3:1: missing return`
+	assert.Equal(t, []QuickfixEntry{
+		{"./main.go", 7, 1, "undefined: fmt"},
+		{"./util.go", 2, 0, "missing return"},
+	}, parseWith("go", output))
+}
+
+func TestParseErrorsRustc(t *testing.T) {
+	output := "error[E0425]: cannot find value `y` in this scope\n" +
+		" --> src/main.rs:3:13\n" +
+		"  |\n" +
+		"3 |     let x = y;\n" +
+		"warning: unused variable: `x`\n" +
+		" --> src/main.rs:3:9"
+	assert.Equal(t, []QuickfixEntry{
+		{"src/main.rs", 2, 12, "error[E0425]: cannot find value `y` in this scope"},
+		{"src/main.rs", 2, 8, "warning: unused variable: `x`"},
+	}, parseWith("rustc", output))
+}
+
+func TestParseErrorsEslint(t *testing.T) {
+	output := `
+/home/user/app/index.js
+  1:7   error    'unused' is assigned a value but never used  no-unused-vars
+  4:1   warning  Unexpected console statement                 no-console
+
+/home/user/app/lib.js
+  2:10  error  Missing semicolon  semi
+
+✖ 3 problems (2 errors, 1 warning)`
+	entries := parseWith("eslint", output)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, QuickfixEntry{"/home/user/app/index.js", 3, 0,
+		"warning  Unexpected console statement                 no-console"}, entries[1])
+	assert.Equal(t, "/home/user/app/lib.js", entries[2].Filename)
+	assert.Equal(t, 1, entries[2].Row)
+	assert.Equal(t, 9, entries[2].Column)
+}
+
+func TestParseErrorsGrep(t *testing.T) {
+	assert.Equal(t, []QuickfixEntry{
+		{"a.txt", 11, 0, "key: 1:2"},
+	}, parseWith("grep", "a.txt:12:key: 1:2\nBinary file b matches"))
+}
+
+func TestParseErrorsAuto(t *testing.T) {
+	output := "main.c:1:2: error: a\n" +
+		"error: b\n" +
+		"  --> lib.rs:3:4\n" +
+		"/abs/file.js\n" +
+		"  5:6  error  c"
+	assert.Equal(t, []QuickfixEntry{
+		{"main.c", 0, 1, "error: a"},
+		{"lib.rs", 2, 3, "error: b"},
+		{"/abs/file.js", 4, 5, "error  c"},
+	}, parseWith("auto", output))
+
+	_, ok := GetErrorFormats("none")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"auto", "eslint", "gcc", "go", "grep", "rustc"}, ErrorFormatNames())
+}
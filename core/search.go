@@ -0,0 +1,159 @@
+package core
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Files bigger than this are skipped by SearchFiles
+const maxSearchFileSize = 16 << 20
+
+// Returns the lines matching the regex in the files inside the directory,
+// skipping the ones ignored by .gitignore files, the .git directory and
+// binary files. The files are read by the workers concurrently, and the
+// entries are sorted by file and line.
+func SearchFiles(root string, regex *regexp.Regexp, workers int) ([]QuickfixEntry, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	files := make(chan string)
+	results := make(chan []QuickfixEntry)
+	var walkErr error
+	go func() {
+		walkErr = walkUnignored(root, func(file string) { files <- file })
+		close(files)
+	}()
+
+	var wait sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for file := range files {
+				if entries := searchFile(file, regex); len(entries) > 0 {
+					results <- entries
+				}
+			}
+		}()
+	}
+	go func() {
+		wait.Wait()
+		close(results)
+	}()
+
+	entries := []QuickfixEntry{}
+	for fileEntries := range results {
+		entries = append(entries, fileEntries...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Filename != entries[j].Filename {
+			return entries[i].Filename < entries[j].Filename
+		}
+		return entries[i].Row < entries[j].Row
+	})
+	return entries, walkErr
+}
+
+// Returns the files inside the directory which are not ignored (see
+// SearchFiles), relative to it and sorted
+func ListFiles(root string) ([]string, error) {
+	files := []string{}
+	err := walkUnignored(root, func(file string) {
+		relative, relErr := filepath.Rel(root, file)
+		if relErr != nil {
+			relative = file
+		}
+		files = append(files, relative)
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Calls found with the regular files inside the directory which are not
+// ignored (see SearchFiles)
+func walkUnignored(root string, found func(file string)) error {
+	// The .gitignore of each directory, or nil
+	gitignores := map[string]*Gitignore{}
+	ignored := func(file string, isDir bool) bool {
+		result := false
+		// From the root to the deepest directory, so the deepest decides
+		relative, _ := filepath.Rel(root, file)
+		parts := strings.Split(filepath.ToSlash(relative), "/")
+		directory := root
+		for i := range parts {
+			if gitignore := gitignores[directory]; gitignore != nil {
+				if ignored, ok := gitignore.Ignored(strings.Join(parts[i:], "/"), isDir); ok {
+					result = ignored
+				}
+			}
+			directory = filepath.Join(directory, parts[i])
+		}
+		return result
+	}
+
+	return filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable files and directories are skipped
+			if file == root {
+				return err
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if file != root && (entry.Name() == ".git" || ignored(file, true)) {
+				return filepath.SkipDir
+			}
+			gitignores[file] = LoadGitignore(file)
+			return nil
+		}
+		if entry.Type().IsRegular() && !ignored(file, false) {
+			found(file)
+		}
+		return nil
+	})
+}
+
+func searchFile(file string, regex *regexp.Regexp) []QuickfixEntry {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return nil
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	if IsBinary(contents) {
+		return nil
+	}
+
+	entries := []QuickfixEntry{}
+	for row, line := range strings.Split(string(contents), "\n") {
+		match := regex.FindStringIndex(line)
+		if match == nil {
+			continue
+		}
+		entries = append(entries, QuickfixEntry{
+			Filename: file,
+			Row:      row,
+			Column:   utf8.RuneCountInString(line[:match[0]]),
+			Message:  strings.TrimSpace(line),
+		})
+	}
+	return entries
+}
+
+// Like grep, files with a null byte near the start are binary
+func IsBinary(contents []byte) bool {
+	start := contents
+	if len(start) > 8000 {
+		start = start[:8000]
+	}
+	return bytes.IndexByte(start, 0) >= 0
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":         "*.log\nvendor/\n",
+		"main.go":            "package main\n\nfunc main() { todo() }\n",
+		"debug.log":          "todo\n",
+		"vendor/lib.go":      "todo\n",
+		"sub/.gitignore":     "!keep.log\ngenerated.go\n",
+		"sub/keep.log":       "x todo\n",
+		"sub/generated.go":   "todo\n",
+		"sub/util.go":        "// ñtodo: ñ\n",
+		"sub/data.bin":       "todo\x00\n",
+		".git/HEAD":          "todo\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	entries, err := SearchFiles(root, regexp.MustCompile(`todo`), 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []QuickfixEntry{
+		{filepath.Join(root, "main.go"), 2, 14, "func main() { todo() }"},
+		{filepath.Join(root, "sub/keep.log"), 0, 2, "x todo"},
+		{filepath.Join(root, "sub/util.go"), 0, 4, "// ñtodo: ñ"},
+	}, entries)
+
+	_, err = SearchFiles(filepath.Join(root, "missing"), regexp.MustCompile(`todo`), 4)
+	assert.NotNil(t, err)
+
+	listed, err := ListFiles(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		".gitignore",
+		"main.go",
+		"sub/.gitignore",
+		"sub/data.bin",
+		"sub/keep.log",
+		"sub/util.go",
+	}, listed)
+}
@@ -0,0 +1,158 @@
+package core
+
+import "regexp"
+
+var word = regexp.MustCompile(`\w+`)
+
+// The range of the whole buffer
+func bufferRange(editor *Editor) Range {
+	lastRow := editor.Buffer.GetLength() - 1
+	if lastRow < 0 {
+		return Range{}
+	}
+	return Range{End: Location{lastRow, ColumnSpan(editor, editor.Buffer.GetLine(lastRow))}}
+}
+
+// Cursors selecting the matches of the regex in the range, with the
+// registers of the template. Empty matches are skipped.
+func matchCursors(editor *Editor, regex *regexp.Regexp, rangee Range, template Cursor) []*Cursor {
+	cursorIncludeNewline(editor, &rangee)
+	ranges, _ := findMatches(editor, regex, rangeText(editor, rangee), rangee.Start, -1)
+	cursors := []*Cursor{}
+	for _, match := range ranges {
+		if match.Start == match.End {
+			continue
+		}
+		cursor := template
+		cursor.Range = match
+		cursors = append(cursors, &cursor)
+	}
+	return cursors
+}
+
+// Replaces the cursors with one selecting each match of the regex in the
+// buffer. If there are no matches, the cursors are kept.
+func CursorPerMatch(regex *regexp.Regexp) Edit {
+	return func(editor *Editor) {
+		var template Cursor
+		if len(editor.Cursors) > 0 {
+			template = *editor.Cursors[len(editor.Cursors)-1]
+		}
+		cursors := matchCursors(editor, regex, bufferRange(editor), template)
+		if len(cursors) > 0 {
+			editor.Cursors = cursors
+		}
+	}
+}
+
+// Replaces each cursor with one selecting each match of the regex inside
+// its selection. Cursors without matches are removed, unless none of them
+// has any.
+func CursorPerMatchInSelections(regex *regexp.Regexp) Edit {
+	return func(editor *Editor) {
+		cursors := []*Cursor{}
+		for _, cursor := range SortCursors(editor.Cursors) {
+			if !IsOOB(editor, cursor) {
+				cursors = append(cursors, matchCursors(editor, regex, cursor.Range, *cursor)...)
+			}
+		}
+		if len(cursors) > 0 {
+			editor.Cursors = cursors
+		}
+	}
+}
+
+// If the last cursor selects a single character, it selects the word under
+// it instead. Otherwise, a cursor is added in the next match of the text it
+// selects, wrapping around the end of the buffer, like Sublime Text's
+// <C-d>. If skip is true, the last cursor is moved to the match instead.
+func SelectNextMatch(skip bool) Edit {
+	return func(editor *Editor) {
+		if len(editor.Cursors) == 0 {
+			return
+		}
+		last := editor.Cursors[len(editor.Cursors)-1]
+		if IsOOB(editor, last) {
+			return
+		}
+		if last.Start.Row == last.End.Row && last.End.Column - last.Start.Column <= 1 {
+			selectWord(editor, last)
+			return
+		}
+
+		selected := last.Range
+		cursorIncludeNewline(editor, &selected)
+		regex := regexp.MustCompile(regexp.QuoteMeta(rangeText(editor, selected)))
+		matches := matchCursors(editor, regex, bufferRange(editor), *last)
+
+		// The matches after the last cursor come first
+		first := len(matches)
+		for i, match := range matches {
+			if comesFirst(last.Start, match.Start) {
+				first = i
+				break
+			}
+		}
+		matches = append(matches[first:], matches[:first]...)
+		for _, match := range matches {
+			if isWithinACursor(editor, match) {
+				continue
+			}
+			if skip {
+				last.Range = match.Range
+			} else {
+				PushCursor(match)(editor)
+			}
+			return
+		}
+	}
+}
+
+func selectWord(editor *Editor, cursor *Cursor) {
+	line := editor.Buffer.GetLine(cursor.Start.Row)
+	index := LocationToIndex(editor, cursor.Start)
+	for _, match := range word.FindAllStringIndex(string(line), -1) {
+		start := len([]rune(string(line)[:match[0]]))
+		end := len([]rune(string(line)[:match[1]]))
+		if start <= index && index < end {
+			cursor.Start.Column = ColumnSpan(editor, line[:start])
+			cursor.End.Column = ColumnSpan(editor, line[:end])
+			return
+		}
+	}
+}
+
+// Returns the selections of the cursors, or their lines for the cursors that
+// select a single character (like :s does), as matches replaced by the result
+// of the function on their text. It stops at the first error.
+func SelectionReplacements(editor *Editor, replace func(text string) (string, error)) ([]Match, error) {
+	selections := []Match{}
+	selectedRows := map[int]bool{}
+	for _, cursor := range SortCursors(editor.Cursors) {
+		if IsOOB(editor, cursor) {
+			continue
+		}
+		rangee := cursor.Range
+		if rangee.Start.Row == rangee.End.Row && rangee.End.Column - rangee.Start.Column <= 1 {
+			row := rangee.Start.Row
+			if selectedRows[row] {
+				continue
+			}
+			selectedRows[row] = true
+			rangee = Range{Location{row, 0}, Location{row, ColumnSpan(editor, editor.Buffer.GetLine(row))}}
+		} else {
+			cursorIncludeNewline(editor, &rangee)
+		}
+		selections = append(selections, Match{Range: rangee})
+	}
+
+	matches := withoutOverlaps(selections)
+	for i, match := range matches {
+		replacement, err := replace(rangeText(editor, match.Range))
+		if err != nil {
+			return nil, err
+		}
+		matches[i].Replacement = []rune(replacement)
+	}
+	return matches, nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cursorRanges(e *Editor) []Range {
+	ranges := []Range{}
+	for _, cursor := range e.Cursors {
+		ranges = append(ranges, cursor.Range)
+	}
+	return ranges
+}
+
+func TestCursorPerMatch(t *testing.T) {
+	e := substituteTestEditor("foo bar", "bar foo")
+	e.Cursors[0].Registers[0] = []rune("yanked")
+	CursorPerMatch(regexp.MustCompile(`foo`))(e)
+	assert.Equal(t, []Range{
+		{Location{0, 0}, Location{0, 3}},
+		{Location{1, 4}, Location{1, 7}},
+	}, cursorRanges(e))
+	assert.Equal(t, []rune("yanked"), e.Cursors[1].Registers[0])
+
+	// The cursors are kept if nothing matches
+	CursorPerMatch(regexp.MustCompile(`baz`))(e)
+	assert.Equal(t, 2, len(e.Cursors))
+}
+
+func TestCursorPerMatchInSelections(t *testing.T) {
+	e := substituteTestEditor("a a a", "a a a")
+	e.Cursors = nil
+	SetCursors(0,2,0,5, 1,0,1,1)(e)
+	CursorPerMatchInSelections(regexp.MustCompile(`a`))(e)
+	assert.Equal(t, []Range{
+		{Location{0, 2}, Location{0, 3}},
+		{Location{0, 4}, Location{0, 5}},
+		{Location{1, 0}, Location{1, 1}},
+	}, cursorRanges(e))
+}
+
+func TestSelectNextMatch(t *testing.T) {
+	e := substituteTestEditor("foo foobar", "foo")
+	e.Cursors = nil
+	SetCursors(0,5,0,6)(e)
+
+	SelectNextMatch(false)(e)
+	assert.Equal(t, []Range{{Location{0, 4}, Location{0, 10}}}, cursorRanges(e))
+
+	e.Cursors = nil
+	SetCursors(0,4,0,7)(e)
+	SelectNextMatch(false)(e)
+	SelectNextMatch(false)(e)
+	assert.Equal(t, []Range{
+		{Location{0, 4}, Location{0, 7}},
+		{Location{1, 0}, Location{1, 3}},
+		{Location{0, 0}, Location{0, 3}},
+	}, cursorRanges(e))
+
+	// Every match has a cursor
+	SelectNextMatch(false)(e)
+	assert.Equal(t, 3, len(e.Cursors))
+
+	e.Cursors = nil
+	SetCursors(0,0,0,3)(e)
+	SelectNextMatch(true)(e)
+	assert.Equal(t, []Range{{Location{0, 4}, Location{0, 7}}}, cursorRanges(e))
+}
+
+func TestSelectionReplacements(t *testing.T) {
+	e := substituteTestEditor("one two", "three", "four")
+	e.Cursors = nil
+	SetCursors(0,4,0,7, 1,2,1,3, 1,0,1,1, 2,1,2,2)(e)
+	matches, err := SelectionReplacements(e, func(text string) (string, error) {
+		if text == "four" {
+			return "", errors.New("four")
+		}
+		return strings.ToUpper(text), nil
+	})
+	assert.Nil(t, matches)
+	assert.EqualError(t, err, "four")
+
+	e.Cursors = e.Cursors[:3]
+	matches, err = SelectionReplacements(e, func(text string) (string, error) {
+		return strings.ToUpper(text) + "!", nil
+	})
+	assert.Nil(t, err)
+	ReplaceMatches(matches)(e)
+	assert.Equal(t, ToRune([]string{"one TWO!", "THREE!", "four"}), lines(e))
+}
@@ -0,0 +1,267 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A search and replace, like Vim's :s/pattern/replacement/flags
+type Substitution struct {
+	Regex       *regexp.Regexp
+	// Expanded with the captures of each match, so $1, ${1} and ${name}
+	// can be used
+	Replacement string
+	Global      bool // Replace every match in a line, not only the first
+	Confirm     bool // Ask before each replacement
+}
+
+// A part of the buffer to be replaced
+type Match struct {
+	Range
+	Replacement []rune
+}
+
+var ErrInvalidSubstitution = errors.New("invalid substitution, use /pattern/replacement/flags")
+
+// Parses "/pattern/replacement/flags", where the delimiter can be any
+// character, and can be included in the pattern or replacement escaping it
+// with a backslash. The flags are g (global), i (ignore case) and c (confirm).
+func ParseSubstitution(command string) (*Substitution, error) {
+	delimiter, size := utf8.DecodeRuneInString(command)
+	if size == 0 || delimiter == '\\' || delimiter == ' ' {
+		return nil, ErrInvalidSubstitution
+	}
+	parts := splitEscaped(command[size:], delimiter)
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return nil, ErrInvalidSubstitution
+	}
+	pattern := parts[0]
+	substitution := &Substitution{Replacement: unescapeReplacement(parts[1])}
+	if len(parts) == 3 {
+		for _, flag := range parts[2] {
+			switch flag {
+			case 'g':
+				substitution.Global = true
+			case 'c':
+				substitution.Confirm = true
+			case 'i':
+				pattern = "(?i)" + pattern
+			default:
+				return nil, errors.New("unknown flag: " + string(flag))
+			}
+		}
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	substitution.Regex = regex
+	return substitution, nil
+}
+
+// Splits by the delimiter, unless it is escaped, in which case the backslash
+// is removed. Other escapes are kept for the regex.
+func splitEscaped(str string, delimiter rune) []string {
+	parts := []string{}
+	for {
+		part, rest, closed := untilDelimiter(str, delimiter)
+		parts = append(parts, part)
+		if !closed {
+			return parts
+		}
+		str = rest
+	}
+}
+
+// Returns the text until the first unescaped delimiter, the text after it,
+// and whether the delimiter was found. Escaped delimiters lose the backslash.
+func untilDelimiter(str string, delimiter rune) (part string, rest string, closed bool) {
+	runes := []rune{}
+	escaped := false
+	for i, chr := range str {
+		switch {
+		case escaped && chr == delimiter:
+			runes = append(runes, chr)
+		case escaped:
+			runes = append(runes, '\\', chr)
+		case chr == '\\':
+			escaped = true
+			continue
+		case chr == delimiter:
+			return string(runes), str[i+utf8.RuneLen(chr):], true
+		default:
+			runes = append(runes, chr)
+		}
+		escaped = false
+	}
+	if escaped {
+		runes = append(runes, '\\')
+	}
+	return string(runes), "", false
+}
+
+var replacementEscapes = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t")
+
+func unescapeReplacement(replacement string) string {
+	return replacementEscapes.Replace(replacement)
+}
+
+// Returns the matches in the rows from start to end (exclusive). Matches
+// don't span multiple lines.
+func (s *Substitution) LineMatches(editor *Editor, start, end int) []Match {
+	matches := []Match{}
+	for row := start; row < end && row < editor.Buffer.GetLength(); row++ {
+		line := string(editor.Buffer.GetLine(row))
+		matches = append(matches, s.matches(editor, line, Location{row, 0})...)
+	}
+	return matches
+}
+
+// Returns the matches inside the range, which can span multiple lines
+func (s *Substitution) RangeMatches(editor *Editor, rangee Range) []Match {
+	cursorIncludeNewline(editor, &rangee)
+	return s.matches(editor, rangeText(editor, rangee), rangee.Start)
+}
+
+// The text in the range, with the lines separated by newlines. The range
+// must include the newline (see cursorIncludeNewline).
+func rangeText(editor *Editor, rangee Range) string {
+	lines := []string{}
+	for row := rangee.Start.Row; row <= rangee.End.Row && row < editor.Buffer.GetLength(); row++ {
+		line := editor.Buffer.GetLine(row)
+		if row == rangee.End.Row {
+			line = slice(line, 0, LocationToIndex(editor, rangee.End))
+		}
+		if row == rangee.Start.Row {
+			line = slice(line, LocationToIndex(editor, rangee.Start), -1)
+		}
+		lines = append(lines, string(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns the matches in the selections of the cursors, or in their lines
+// for the cursors that select a single character.
+func (s *Substitution) CursorMatches(editor *Editor) []Match {
+	matches := []Match{}
+	searchedRows := map[int]bool{}
+	for _, cursor := range SortCursors(editor.Cursors) {
+		if IsOOB(editor, cursor) {
+			continue
+		}
+		if cursor.Start.Row == cursor.End.Row && cursor.End.Column - cursor.Start.Column <= 1 {
+			if !searchedRows[cursor.Start.Row] {
+				searchedRows[cursor.Start.Row] = true
+				matches = append(matches, s.LineMatches(editor, cursor.Start.Row, cursor.Start.Row + 1)...)
+			}
+			continue
+		}
+		matches = append(matches, s.RangeMatches(editor, cursor.Range)...)
+	}
+	return withoutOverlaps(matches)
+}
+
+// Matches in the text, which starts at the location in the buffer
+func (s *Substitution) matches(editor *Editor, text string, start Location) []Match {
+	limit := 1
+	if s.Global {
+		limit = -1
+	}
+	ranges, submatches := findMatches(editor, s.Regex, text, start, limit)
+	matches := make([]Match, len(ranges))
+	for i, submatch := range submatches {
+		matches[i] = Match{
+			Range:       ranges[i],
+			Replacement: []rune(string(s.Regex.ExpandString(nil, s.Replacement, text, submatch))),
+		}
+	}
+	return matches
+}
+
+// Finds up to limit (or all, if negative) matches of the regex in the text,
+// which starts at the location in the buffer. Returns their ranges, and the
+// indexes of their submatches in the text.
+func findMatches(editor *Editor, regex *regexp.Regexp, text string, start Location, limit int) ([]Range, [][]int) {
+	submatches := regex.FindAllStringSubmatchIndex(text, limit)
+	offsets := []int{}
+	for _, submatch := range submatches {
+		offsets = append(offsets, submatch[0], submatch[1])
+	}
+	locations := locateOffsets(editor, text, start, offsets)
+
+	ranges := make([]Range, len(submatches))
+	for i := range submatches {
+		ranges[i] = Range{locations[2*i], locations[2*i+1]}
+	}
+	return ranges, submatches
+}
+
+// Converts the sorted byte offsets in the text to locations in the buffer
+func locateOffsets(editor *Editor, text string, start Location, offsets []int) []Location {
+	locations := make([]Location, len(offsets))
+	location := start
+	i := 0
+	for offset, chr := range text {
+		for i < len(offsets) && offsets[i] <= offset {
+			locations[i] = location
+			i++
+		}
+		if chr == '\n' {
+			location.Row++
+			location.Column = 0
+		} else {
+			location.Column += RuneWidth(editor, chr)
+		}
+	}
+	for ; i < len(offsets); i++ {
+		locations[i] = location
+	}
+	return locations
+}
+
+// Sorts the matches, dropping the ones that overlap a previous one
+func withoutOverlaps(matches []Match) []Match {
+	sort.SliceStable(matches, func(i, j int) bool {
+		return comesFirst(matches[i].Start, matches[j].Start)
+	})
+	kept := []Match{}
+	for _, match := range matches {
+		if len(kept) > 0 && comesFirst(match.Start, kept[len(kept)-1].End) {
+			continue
+		}
+		kept = append(kept, match)
+	}
+	return kept
+}
+
+// Replaces the matches, which must be sorted and not overlap. They are
+// replaced from last to first, so the locations of the rest stay valid.
+func ReplaceMatches(matches []Match) Edit {
+	return func(editor *Editor) {
+		for i := len(matches) - 1; i >= 0; i-- {
+			match := matches[i]
+			if match.Start != match.End {
+				SingleDelete(match.Range)(editor)
+			}
+			singleInsert(match.Replacement, match.Start)(editor)
+		}
+		removeOOBCursors(editor)
+	}
+}
+
+// Inserts at the location, which can include newlines
+func singleInsert(insertion []rune, location Location) Edit {
+	return func(editor *Editor) {
+		for i, line := range splitRune(insertion, '\n') {
+			if i > 0 {
+				SingleSplit(location.Row, location.Column)(editor)
+				location = Location{location.Row + 1, 0}
+			}
+			SingleInsertInLine(line, location.Row, location.Column)(editor)
+			location.Column += ColumnSpan(editor, line)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func substituteTestEditor(text ...string) *Editor {
+	b := NewBuffer()
+	b.Current = b.Current.Insert(0, ToRune(text))
+	e := &Editor{Buffer: b}
+	SetCursors(0,0,0,1)(e)
+	return e
+}
+
+func TestParseSubstitution(t *testing.T) {
+	s, err := ParseSubstitution(`/a\/b/c\nd/gc`)
+	assert.Nil(t, err)
+	assert.Equal(t, "a/b", s.Regex.String())
+	assert.Equal(t, "c\nd", s.Replacement)
+	assert.True(t, s.Global)
+	assert.True(t, s.Confirm)
+
+	s, err = ParseSubstitution(`#x#y#i`)
+	assert.Nil(t, err)
+	assert.True(t, s.Regex.MatchString("X"))
+
+	_, err = ParseSubstitution(`/x`)
+	assert.Equal(t, ErrInvalidSubstitution, err)
+	_, err = ParseSubstitution(`/x/y/z`)
+	assert.NotNil(t, err)
+	_, err = ParseSubstitution(`/(/y/`)
+	assert.NotNil(t, err)
+}
+
+func TestSubstituteCaptures(t *testing.T) {
+	e := substituteTestEditor("key = value", "a = b = c", "none")
+	s, _ := ParseSubstitution(`/(?P<key>\w+) = (\w+)/$2 = ${key}/`)
+	ReplaceMatches(s.LineMatches(e, 0, 3))(e)
+	assert.Equal(t, ToRune([]string{"value = key", "b = a = c", "none"}), lines(e))
+}
+
+func TestSubstituteGlobal(t *testing.T) {
+	e := substituteTestEditor("aaa", "bab")
+	s, _ := ParseSubstitution(`/a/xy/`)
+	ReplaceMatches(s.LineMatches(e, 0, 2))(e)
+	assert.Equal(t, ToRune([]string{"xyaa", "bxyb"}), lines(e))
+
+	s, _ = ParseSubstitution(`/a/xy/g`)
+	ReplaceMatches(s.LineMatches(e, 0, 2))(e)
+	assert.Equal(t, ToRune([]string{"xyxyxy", "bxyb"}), lines(e))
+}
+
+func TestSubstituteNewlines(t *testing.T) {
+	e := substituteTestEditor("a,b", "c")
+	s, _ := ParseSubstitution(`/,/\n/`)
+	ReplaceMatches(s.LineMatches(e, 0, 2))(e)
+	assert.Equal(t, ToRune([]string{"a", "b", "c"}), lines(e))
+}
+
+func TestSubstituteSelection(t *testing.T) {
+	e := substituteTestEditor("aaaa", "aaaa", "aaaa")
+	e.Cursors = nil
+	SetCursors(0,2,1,2, 2,3,2,4)(e)
+	s, _ := ParseSubstitution(`/a/b/g`)
+	matches := s.CursorMatches(e)
+	assert.Equal(t, 8, len(matches))
+	ReplaceMatches(matches)(e)
+	assert.Equal(t, ToRune([]string{"aabb", "bbaa", "bbbb"}), lines(e))
+
+	// Selections can match across lines
+	e = substituteTestEditor("ab", "cd")
+	e.Cursors = nil
+	SetCursors(0,1,1,1)(e)
+	s, _ = ParseSubstitution(`/b\nc/-/`)
+	ReplaceMatches(s.CursorMatches(e))(e)
+	assert.Equal(t, ToRune([]string{"a-d"}), lines(e))
+}
+
+func TestSubstituteMovesCursors(t *testing.T) {
+	e := substituteTestEditor("a a b", "a")
+	e.Cursors = nil
+	SetCursors(0,4,0,5, 1,0,1,1)(e)
+	s, _ := ParseSubstitution(`/a/xyz/g`)
+	ReplaceMatches(s.LineMatches(e, 0, 1))(e)
+	assert.Equal(t, ToRune([]string{"xyz xyz b", "a"}), lines(e))
+	assert.Equal(t, Range{Location{0, 8}, Location{0, 9}}, e.Cursors[0].Range)
+	assert.Equal(t, Range{Location{1, 0}, Location{1, 1}}, e.Cursors[1].Range)
+}
@@ -0,0 +1,59 @@
+package core
+
+import "time"
+
+// Commits the current state as written to (or read from) a file, for
+// UndoSaves and Modified
+func (e *Editor) MarkSaved() {
+	e.commit()
+	h := &e.History
+	h.saves++
+	h.Current.Save = h.saves
+}
+
+// Whether the contents may differ from the last time MarkSaved was called,
+// which is when the current revision is not the one saved then, or the buffer
+// changed after it
+func (e *Editor) Modified() bool {
+	h := &e.History
+	return h.Current == nil || h.saves == 0 || h.Current.Save != h.saves || !e.Buffer.IsVersion(h.Current.Version)
+}
+
+// Moves to the latest revision created at least the duration before (or
+// after, if positive) the current one was.
+func (e *Editor) UndoTime(duration time.Duration) {
+	e.commit()
+	h := &e.History
+	target := h.Current.Time.Add(duration)
+	index := e.revisionIndex(h.Current.Version)
+	if duration < 0 {
+		for index > 0 && h.Revisions[index].Time.After(target) {
+			index--
+		}
+	} else {
+		for index < len(h.Revisions) - 1 && !h.Revisions[index+1].Time.After(target) {
+			index++
+		}
+	}
+	e.goToRevision(h.Revisions[index])
+}
+
+// Moves to the state of the file when it was written, the amount of saves
+// before (or after, if positive). If there aren't enough, it moves to the
+// first (or last) revision.
+func (e *Editor) UndoSaves(saves int) {
+	e.commit()
+	h := &e.History
+	index := e.revisionIndex(h.Current.Version)
+	step := 1
+	if saves < 0 {
+		step = -1
+	}
+	for saves != 0 && index + step >= 0 && index + step < len(h.Revisions) {
+		index += step
+		if h.Revisions[index].Save > 0 {
+			saves -= step
+		}
+	}
+	e.goToRevision(h.Revisions[index])
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoTime(t *testing.T) {
+	e := undoTestEditor()
+	now := time.Date(2022, 3, 17, 0, 0, 0, 0, time.UTC)
+	e.Versions.Now = func() time.Time { return now }
+
+	states := [][][]rune{}
+	for _, chr := range "abcd" {
+		e.MarkUndo()
+		states = append(states, lines(e))
+		AsEdit(Insert([]rune{chr}))(e)
+		now = now.Add(time.Minute)
+	}
+	e.UndoChronological(0)
+	states = append(states, lines(e))
+
+	e.UndoTime(-2 * time.Minute)
+	assert.Equal(t, states[2], lines(e))
+	e.UndoTime(-90 * time.Second)
+	assert.Equal(t, states[0], lines(e))
+	e.UndoTime(-time.Hour)
+	assert.Equal(t, states[0], lines(e))
+	e.UndoTime(90 * time.Second)
+	assert.Equal(t, states[1], lines(e))
+	e.UndoTime(time.Hour)
+	assert.Equal(t, states[4], lines(e))
+}
+
+func TestUndoSaves(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	assert.Nil(t, SaveToFile(e, filename))
+	saveA := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b")))(e)
+	assert.Nil(t, SaveToFile(e, filename))
+	saveB := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("c")))(e)
+	unsaved := lines(e)
+
+	e.UndoSaves(-1)
+	assert.Equal(t, saveB, lines(e))
+	e.UndoSaves(-1)
+	assert.Equal(t, saveA, lines(e))
+	e.UndoSaves(-5)
+	assert.Equal(t, original, lines(e))
+	e.UndoSaves(2)
+	assert.Equal(t, saveB, lines(e))
+	e.UndoSaves(1)
+	assert.Equal(t, unsaved, lines(e))
+}
+
+func TestModified(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	e := undoTestEditor()
+	assert.True(t, e.Modified())
+
+	e.MarkSaved()
+	assert.False(t, e.Modified())
+
+	e.MarkUndo()
+	assert.False(t, e.Modified())
+	AsEdit(Insert([]rune("a")))(e)
+	assert.True(t, e.Modified())
+
+	e.Undo()
+	assert.False(t, e.Modified())
+	e.Redo()
+	assert.True(t, e.Modified())
+
+	assert.Nil(t, SaveToFile(e, filename))
+	assert.False(t, e.Modified())
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// A node in the undo tree, holding a saved state of the buffer and cursors
+type Revision struct {
+	Version  Version
+	Time     time.Time
+	Label    string // Describes the change from the parent, e.g. "insert"
+	Save     int    // Number of the write to a file done in this state, or 0
+	Parent   *Revision
+	Children []*Revision
+	redo     int // Index of the child followed by Redo
+	size     int // Estimated bytes used, 0 if not yet calculated
+}
+
+type UndoTree struct {
+	Root      *Revision
+	Current   *Revision
+	Revisions []*Revision // In chronological order
+	// Whether the buffer may have changed since Current was saved
+	pending   bool
+	label     string // Of the pending changes
+	saves     int
+	grouped   int    // Depth of StartUndoGroup calls
+	// The total of the sizes of the revisions, kept up to date once sized
+	// (see HistorySize)
+	size      int
+	sized     bool
+}
+
+// A path from the revision where it forks (or the root) to a leaf
+type Branch struct {
+	Fork         *Revision
+	Tip          *Revision
+	Length       int // Revisions after the fork
+	ChangedLines int // Between the fork and the tip
+}
+
+// Marks the start of an action to be undone
+func (e *Editor) MarkUndo() {
+	e.MarkLabeledUndo("")
+}
+
+// Like MarkUndo, but the revision created for the action will have the label
+func (e *Editor) MarkLabeledUndo(label string) {
+	if e.History.grouped > 0 {
+		return
+	}
+	e.mark(label)
+}
+
+func (e *Editor) mark(label string) {
+	h := &e.History
+	if h.Current == nil {
+		h.Root = e.newRevision(nil)
+		h.Current = h.Root
+	} else if h.pending {
+		h.Current = e.newRevision(h.Current)
+	} else {
+		// Current is already saved, but the cursors may have moved
+		e.uncountSize(h.Current)
+		e.backup(h.Current.Version)
+		h.Current.size = 0
+		e.countSize(h.Current)
+	}
+	h.pending = true
+	h.label = label
+	e.Prune()
+}
+
+// Makes the actions until EndUndoGroup a single one with the label, by
+// ignoring the MarkUndo calls in between. Groups can be nested, in which case
+// the outermost one is used.
+func (e *Editor) StartUndoGroup(label string) {
+	e.MarkLabeledUndo(label)
+	e.History.grouped++
+}
+
+func (e *Editor) EndUndoGroup() {
+	e.History.grouped--
+}
+
+// Saves the pending changes, if any, as a new revision
+func (e *Editor) commit() {
+	h := &e.History
+	if h.pending || h.Current == nil {
+		e.mark(h.label)
+		// Inside a group, the following changes are still part of it
+		h.pending = h.grouped > 0
+	}
+}
+
+func (e *Editor) newRevision(parent *Revision) *Revision {
+	revision := &Revision{Parent: parent}
+	revision.Version, revision.Time = e.Versions.Next()
+	if parent != nil {
+		revision.Label = e.History.label
+	}
+	e.backup(revision.Version)
+	if parent != nil {
+		parent.Children = append(parent.Children, revision)
+		parent.redo = len(parent.Children) - 1
+	}
+	e.History.Revisions = append(e.History.Revisions, revision)
+	e.countSize(revision)
+	return revision
+}
+
+func (e *Editor) Undo() {
+	e.commit()
+	h := &e.History
+	if h.Current.Parent != nil {
+		h.Current = h.Current.Parent
+	}
+	e.restoreVersion(h.Current.Version)
+}
+
+// Follows the most recently used branch
+func (e *Editor) Redo() {
+	h := &e.History
+	if h.pending || h.Current == nil || len(h.Current.Children) == 0 {
+		return
+	}
+	h.Current = h.Current.Children[h.Current.redo]
+	e.restoreVersion(h.Current.Version)
+}
+
+// Moves through the revisions in the order they were created, regardless of
+// the branch they are in (like Vim's g- and g+).
+func (e *Editor) UndoChronological(steps int) {
+	e.commit()
+	h := &e.History
+	index := e.revisionIndex(h.Current.Version) + steps
+	if index < 0 {
+		index = 0
+	} else if index > len(h.Revisions) - 1 {
+		index = len(h.Revisions) - 1
+	}
+	e.goToRevision(h.Revisions[index])
+}
+
+// Moves to the revision with the version, in any branch
+func (e *Editor) GoToVersion(version Version) error {
+	e.commit()
+	index := e.revisionIndex(version)
+	if index >= len(e.History.Revisions) || e.History.Revisions[index].Version != version {
+		return ErrRevisionNotFound
+	}
+	e.goToRevision(e.History.Revisions[index])
+	return nil
+}
+
+// As versions are increasing, the revisions are sorted by them
+func (e *Editor) revisionIndex(version Version) int {
+	return sort.Search(len(e.History.Revisions), func(i int) bool {
+		return e.History.Revisions[i].Version >= version
+	})
+}
+
+// Moves to a sibling of the current revision, wrapping around
+func (e *Editor) SwitchBranch(steps int) {
+	e.commit()
+	h := &e.History
+	parent := h.Current.Parent
+	if parent == nil {
+		return
+	}
+	index := 0
+	for i, sibling := range parent.Children {
+		if sibling == h.Current {
+			index = i
+		}
+	}
+	index = (index + steps) % len(parent.Children)
+	if index < 0 {
+		index += len(parent.Children)
+	}
+	e.goToRevision(parent.Children[index])
+}
+
+// Restores the revision, and makes Redo follow the path to it
+func (e *Editor) goToRevision(revision *Revision) {
+	for child := revision; child.Parent != nil; child = child.Parent {
+		for i, sibling := range child.Parent.Children {
+			if sibling == child {
+				child.Parent.redo = i
+			}
+		}
+	}
+	e.History.Current = revision
+	e.History.pending = false
+	e.restoreVersion(revision.Version)
+}
+
+// Returns one branch per leaf of the undo tree, in chronological order
+func (e *Editor) Branches() []Branch {
+	branches := []Branch{}
+	for _, revision := range e.History.Revisions {
+		if len(revision.Children) > 0 {
+			continue
+		}
+		branch := Branch{Tip: revision, Fork: revision}
+		for branch.Fork.Parent != nil {
+			branch.Fork = branch.Fork.Parent
+			branch.Length++
+			if len(branch.Fork.Children) > 1 {
+				break
+			}
+		}
+		branch.ChangedLines = changedLines(
+			e.Buffer.GetVersion(branch.Fork.Version),
+			e.Buffer.GetVersion(branch.Tip.Version),
+		)
+		branches = append(branches, branch)
+	}
+	return branches
+}
+
+// Amount of lines between the first and last that differ
+func changedLines(a, b [][]rune) int {
+	prefix, suffix := commonLines(a, b)
+	if len(a) > len(b) {
+		return len(a) - prefix - suffix
+	}
+	return len(b) - prefix - suffix
+}
+
+// Amount of lines shared at the start and end of both versions, without
+// overlapping.
+func commonLines(a, b [][]rune) (prefix, suffix int) {
+	for prefix < len(a) && prefix < len(b) && equalLines(a[prefix], b[prefix]) {
+		prefix++
+	}
+	for suffix < len(a) - prefix && suffix < len(b) - prefix &&
+		equalLines(a[len(a)-suffix-1], b[len(b)-suffix-1]) {
+			suffix++
+	}
+	return prefix, suffix
+}
+
+func equalLines(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Saves the current state outside of the undo tree, so that it can be
+// returned to with Rollback.
+func (e *Editor) Checkpoint() Version {
+	checkpoint, _ := e.Versions.Next()
+	e.backup(checkpoint)
+	return checkpoint
+}
+
+// Restores the checkpoint, which can't be used again
+func (e *Editor) Rollback(checkpoint Version) {
+	e.restoreVersion(checkpoint)
+	e.Buffer.DeleteVersion(checkpoint)
+	delete(e.CursorsVersions, checkpoint)
+}
+
+func (e *Editor) backup(version Version) {
+	if e.CursorsVersions == nil {
+		e.CursorsVersions = make(map[Version][]Cursor)
+	}
+	e.Buffer.Backup(version)
+	e.CursorsVersions[version] = backupCursors(e.Cursors)
+}
+
+func (e *Editor) restoreVersion(version Version) {
+	e.Buffer.Restore(version)
+	e.Cursors = restoreCursors(e.CursorsVersions[version])
+}
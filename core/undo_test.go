@@ -0,0 +1,198 @@
+package core
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func undoTestEditor() *Editor {
+	b := NewBuffer()
+	b.Current = b.Current.Insert(0, ToRune([]string{"0000", "1111", "2222", "3333"}))
+	e := &Editor{Buffer: b}
+	SetCursors(1,2,1,3)(e)
+	return e
+}
+
+func TestUndoKeepsBranches(t *testing.T) {
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	branchA := lines(e)
+	e.Undo()
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b")))(e)
+	branchB := lines(e)
+	e.Undo()
+	assert.Equal(t, original, lines(e))
+
+	e.Redo()
+	assert.Equal(t, branchB, lines(e))
+
+	e.SwitchBranch(1)
+	assert.Equal(t, branchA, lines(e))
+
+	e.Undo()
+	e.Redo()
+	assert.Equal(t, branchA, lines(e))
+
+	e.SwitchBranch(-1)
+	assert.Equal(t, branchB, lines(e))
+}
+
+func TestUndoChronological(t *testing.T) {
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	branchA := lines(e)
+	e.Undo()
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b")))(e)
+	branchB := lines(e)
+	e.Undo()
+
+	// Revisions: original, branchA, branchB
+	e.UndoChronological(1)
+	assert.Equal(t, branchA, lines(e))
+	e.UndoChronological(1)
+	assert.Equal(t, branchB, lines(e))
+	e.UndoChronological(100)
+	assert.Equal(t, branchB, lines(e))
+	e.UndoChronological(-2)
+	assert.Equal(t, original, lines(e))
+
+	e.UndoChronological(1)
+	e.Undo()
+	e.Redo()
+	assert.Equal(t, branchA, lines(e))
+}
+
+func TestBranches(t *testing.T) {
+	e := undoTestEditor()
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	e.MarkUndo()
+	AsEdit(Insert([]rune("\n")))(e)
+	e.Undo()
+	e.Undo()
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b")))(e)
+	e.Undo()
+
+	branches := e.Branches()
+	assert.Equal(t, 2, len(branches))
+
+	assert.Equal(t, e.History.Root, branches[0].Fork)
+	assert.Equal(t, 2, branches[0].Length)
+	assert.Equal(t, 2, branches[0].ChangedLines)
+
+	assert.Equal(t, e.History.Root, branches[1].Fork)
+	assert.Equal(t, 1, branches[1].Length)
+	assert.Equal(t, 1, branches[1].ChangedLines)
+}
+
+func TestCheckpoint(t *testing.T) {
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.MarkUndo()
+	checkpoint := e.Checkpoint()
+	AsEdit(Insert([]rune("a")))(e)
+	e.Rollback(checkpoint)
+	assert.Equal(t, original, lines(e))
+	assert.Equal(t, 1, len(e.History.Revisions))
+
+	AsEdit(Insert([]rune("b")))(e)
+	changed := lines(e)
+	e.Undo()
+	assert.Equal(t, original, lines(e))
+	e.Redo()
+	assert.Equal(t, changed, lines(e))
+}
+
+func TestLabels(t *testing.T) {
+	e := undoTestEditor()
+
+	e.MarkLabeledUndo("insert")
+	AsEdit(Insert([]rune("a")))(e)
+	e.MarkLabeledUndo("delete")
+	AsEdit(Delete)(e)
+	e.Undo()
+
+	revisions := e.History.Revisions
+	assert.Equal(t, 3, len(revisions))
+	assert.Equal(t, "", revisions[0].Label)
+	assert.Equal(t, "insert", revisions[1].Label)
+	assert.Equal(t, "delete", revisions[2].Label)
+}
+
+func TestGoToVersion(t *testing.T) {
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b")))(e)
+	e.Undo()
+	e.Undo()
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("c")))(e)
+	branchC := lines(e)
+
+	assert.Nil(t, e.GoToVersion(3))
+	assert.Equal(t, ToRune([]string{"0000", "11ab11", "2222", "3333"}), lines(e))
+	assert.Nil(t, e.GoToVersion(1))
+	assert.Equal(t, original, lines(e))
+	assert.Nil(t, e.GoToVersion(4))
+	assert.Equal(t, branchC, lines(e))
+
+	assert.Equal(t, ErrRevisionNotFound, e.GoToVersion(42))
+	assert.Equal(t, branchC, lines(e))
+}
+
+func TestUndoGroup(t *testing.T) {
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.StartUndoGroup("group")
+	e.MarkLabeledUndo("insert")
+	AsEdit(Insert([]rune("a")))(e)
+	e.StartUndoGroup("nested")
+	e.MarkLabeledUndo("insert")
+	AsEdit(Insert([]rune("b")))(e)
+	e.EndUndoGroup()
+	e.EndUndoGroup()
+
+	e.MarkLabeledUndo("insert")
+	AsEdit(Insert([]rune("c")))(e)
+	e.Undo()
+	e.Undo()
+	assert.Equal(t, original, lines(e))
+	assert.Equal(t, "group", e.History.Revisions[1].Label)
+}
+
+func TestUndoInsideGroup(t *testing.T) {
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.StartUndoGroup("group")
+	AsEdit(Insert([]rune("a")))(e)
+	e.Undo()
+	AsEdit(Insert([]rune("b")))(e)
+	changed := lines(e)
+	e.EndUndoGroup()
+
+	e.Undo()
+	assert.Equal(t, original, lines(e))
+	e.Redo()
+	assert.Equal(t, changed, lines(e))
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrUndoFileOutdated = errors.New("undo file does not match the file contents")
+var ErrUndoFileCorrupt = errors.New("undo file is corrupt")
+
+// The undo tree as stored on disk. Lines are deduplicated in a single table,
+// and every revision only stores the lines that differ from its parent.
+type undoFile struct {
+	Hash      []byte // Of the contents when the undo file was saved
+	Lines     [][]rune
+	Revisions []undoFileRevision // In chronological order
+	Current   int
+}
+
+type undoFileRevision struct {
+	Version Version
+	Parent  int // Index of the parent, -1 for the root
+	Time    time.Time
+	Label   string
+	Save    int
+	Redo    int
+	Prefix  int   // Lines shared with the start of the parent
+	Suffix  int   // Lines shared with the end of the parent
+	Lines   []int // Indexes in the line table of the rest
+	Cursors []Cursor
+}
+
+// Returns the path of the undo file for a file, ".name.wr-undo" in the same
+// directory.
+func UndoFilename(filename string) string {
+	dir, name := filepath.Split(filename)
+	return filepath.Join(dir, "." + name + ".wr-undo")
+}
+
+func contentHash(editor *Editor) ([]byte, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, NewEditorReader(editor, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// Saves the undo tree of the editor next to filename. It should be called
+// after the buffer is written to filename.
+func SaveUndoFile(editor *Editor, filename string) error {
+	editor.commit()
+	hash, err := contentHash(editor)
+	if err != nil {
+		return err
+	}
+	h := &editor.History
+	file := undoFile{Hash: hash, Current: editor.revisionIndex(h.Current.Version)}
+	lineIndexes := make(map[string]int)
+
+	for _, revision := range h.Revisions {
+		lines := editor.Buffer.GetVersion(revision.Version)
+		stored := undoFileRevision{
+			Version: revision.Version,
+			Parent: -1,
+			Time: revision.Time,
+			Label: revision.Label,
+			Save: revision.Save,
+			Redo: revision.redo,
+			Cursors: editor.CursorsVersions[revision.Version],
+		}
+		if revision.Parent != nil {
+			stored.Parent = editor.revisionIndex(revision.Parent.Version)
+			parentLines := editor.Buffer.GetVersion(revision.Parent.Version)
+			stored.Prefix, stored.Suffix = commonLines(parentLines, lines)
+		}
+		for _, line := range lines[stored.Prefix:len(lines)-stored.Suffix] {
+			index, ok := lineIndexes[string(line)]
+			if !ok {
+				index = len(file.Lines)
+				lineIndexes[string(line)] = index
+				file.Lines = append(file.Lines, line)
+			}
+			stored.Lines = append(stored.Lines, index)
+		}
+		file.Revisions = append(file.Revisions, stored)
+	}
+
+	undoFilename := UndoFilename(filename)
+	temporary, err := os.CreateTemp(filepath.Dir(undoFilename), ".wr-undo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	err = gob.NewEncoder(temporary).Encode(file)
+	temporary.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), undoFilename)
+}
+
+// Restores the undo tree saved next to filename into an editor with no
+// history. If the buffer does not match the contents the undo file was saved
+// with, ErrUndoFileOutdated is returned, and if the undo file is not valid,
+// ErrUndoFileCorrupt. In both cases the editor is left untouched.
+func LoadUndoFile(editor *Editor, filename string) error {
+	reader, err := os.Open(UndoFilename(filename))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var file undoFile
+	err = gob.NewDecoder(reader).Decode(&file)
+	if err != nil {
+		return err
+	}
+	hash, err := contentHash(editor)
+	if err != nil {
+		return err
+	}
+	if string(hash) != string(file.Hash) || len(file.Revisions) == 0 {
+		return ErrUndoFileOutdated
+	}
+	if !file.valid() {
+		return ErrUndoFileCorrupt
+	}
+
+	if editor.CursorsVersions == nil {
+		editor.CursorsVersions = make(map[Version][]Cursor)
+	}
+	h := &editor.History
+	*h = UndoTree{}
+	for _, stored := range file.Revisions {
+		revision := &Revision{
+			Version: stored.Version,
+			Time: stored.Time,
+			Label: stored.Label,
+			Save: stored.Save,
+			redo: stored.Redo,
+		}
+		editor.Versions.Skip(stored.Version)
+		if stored.Save > h.saves {
+			h.saves = stored.Save
+		}
+		if stored.Parent >= 0 {
+			revision.Parent = h.Revisions[stored.Parent]
+			revision.Parent.Children = append(revision.Parent.Children, revision)
+			// Made from the parent, so the lines they share are shared in
+			// memory too
+			editor.Buffer.Restore(revision.Parent.Version)
+			for row := editor.Buffer.GetLength() - stored.Suffix - 1; row >= stored.Prefix; row-- {
+				editor.Buffer.RemoveLine(row)
+			}
+			for i, index := range stored.Lines {
+				editor.Buffer.AddLine(stored.Prefix + i, file.Lines[index])
+			}
+			editor.Buffer.Backup(revision.Version)
+		} else {
+			h.Root = revision
+			lines := [][]rune{}
+			for _, index := range stored.Lines {
+				lines = append(lines, file.Lines[index])
+			}
+			editor.Buffer.SetVersion(revision.Version, lines)
+		}
+		editor.CursorsVersions[revision.Version] = stored.Cursors
+		h.Revisions = append(h.Revisions, revision)
+	}
+	h.Current = h.Revisions[file.Current]
+	editor.restoreVersion(h.Current.Version)
+	return nil
+}
+
+// Whether the revisions can be restored: only the first one is the root, the
+// parents come before their children, the versions increase, the shared
+// lines are in the parent, and the indexes, redos and cursors are in range
+func (file *undoFile) valid() bool {
+	if file.Current < 0 || file.Current >= len(file.Revisions) {
+		return false
+	}
+	lengths := make([]int, len(file.Revisions))
+	children := make([]int, len(file.Revisions))
+	lastVersion := loadVersion
+	for i, stored := range file.Revisions {
+		if stored.Version <= lastVersion {
+			return false
+		}
+		lastVersion = stored.Version
+		parentLength := 0
+		if i == 0 {
+			if stored.Parent != -1 {
+				return false
+			}
+		} else if stored.Parent < 0 || stored.Parent >= i {
+			return false
+		} else {
+			parentLength = lengths[stored.Parent]
+			children[stored.Parent]++
+		}
+		if stored.Prefix < 0 || stored.Suffix < 0 || stored.Prefix + stored.Suffix > parentLength {
+			return false
+		}
+		for _, index := range stored.Lines {
+			if index < 0 || index >= len(file.Lines) {
+				return false
+			}
+		}
+		lengths[i] = stored.Prefix + len(stored.Lines) + stored.Suffix
+		for _, cursor := range stored.Cursors {
+			if !validRange(cursor.Range, lengths[i]) {
+				return false
+			}
+		}
+	}
+	// A revision without children has a redo of 0
+	for i, stored := range file.Revisions {
+		if stored.Redo < 0 || (stored.Redo > 0 && stored.Redo >= children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the range is in the rows of a buffer with the length. The columns
+// past the end of a line are moved back by the editor, so they are allowed.
+func validRange(r Range, length int) bool {
+	return r.Start.Row >= 0 && r.End.Row < length &&
+		r.Start.Column >= 0 && comesFirst(r.Start, r.End)
+}
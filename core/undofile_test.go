@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoFilename(t *testing.T) {
+	assert.Equal(t, "dir/.file.c.wr-undo", UndoFilename("dir/file.c"))
+	assert.Equal(t, ".file.c.wr-undo", UndoFilename("file.c"))
+}
+
+func TestUndoFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	e := undoTestEditor()
+	original := lines(e)
+
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	branchA := lines(e)
+	e.Undo()
+	e.MarkUndo()
+	AsEdit(Insert([]rune("b\nb")))(e)
+	branchB := lines(e)
+
+	assert.Nil(t, SaveToFile(e, filename))
+	assert.Nil(t, SaveUndoFile(e, filename))
+
+	b := NewBuffer()
+	b.Current = b.Current.Insert(0, CopyLines(branchB))
+	loaded := &Editor{Buffer: b}
+	assert.Nil(t, LoadUndoFile(loaded, filename))
+
+	assert.Equal(t, branchB, lines(loaded))
+	assert.Equal(t, cursors(e), cursors(loaded))
+	assert.Equal(t, len(e.History.Revisions), len(loaded.History.Revisions))
+
+	loaded.Undo()
+	assert.Equal(t, original, lines(loaded))
+	loaded.Redo()
+	assert.Equal(t, branchB, lines(loaded))
+	loaded.SwitchBranch(1)
+	assert.Equal(t, branchA, lines(loaded))
+}
+
+func TestUndoFileOutdated(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	e := undoTestEditor()
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a")))(e)
+	assert.Nil(t, SaveUndoFile(e, filename))
+
+	changed := undoTestEditor()
+	assert.Equal(t, ErrUndoFileOutdated, LoadUndoFile(changed, filename))
+	assert.Nil(t, changed.History.Current)
+
+	missing := undoTestEditor()
+	assert.True(t, os.IsNotExist(LoadUndoFile(missing, filename + "2")))
+}
+
+func TestUndoFileCorrupt(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	e := undoTestEditor()
+	e.MarkUndo()
+	AsEdit(Insert([]rune("a\nb")))(e)
+	e.MarkUndo()
+	AsEdit(Insert([]rune("c")))(e)
+	assert.Nil(t, SaveUndoFile(e, filename))
+
+	corruptions := []func(file *undoFile){
+		func(file *undoFile) { file.Current = len(file.Revisions) },
+		func(file *undoFile) { file.Revisions[1].Parent = 1 },
+		func(file *undoFile) { file.Revisions[1].Parent = -1 },
+		func(file *undoFile) { file.Revisions[2].Suffix = 100 },
+		func(file *undoFile) { file.Revisions[2].Prefix = -1 },
+		func(file *undoFile) { file.Revisions[2].Lines = append(file.Revisions[2].Lines, len(file.Lines)) },
+		func(file *undoFile) { file.Revisions[2].Version = file.Revisions[1].Version },
+		func(file *undoFile) { file.Revisions[0].Version, file.Revisions[1].Version = file.Revisions[1].Version, file.Revisions[0].Version },
+		func(file *undoFile) { file.Revisions[1].Redo = 5 },
+		func(file *undoFile) { file.Revisions[2].Redo = 1 },
+		func(file *undoFile) { file.Revisions[1].Redo = -1 },
+		func(file *undoFile) { file.Revisions[2].Cursors[0].End.Row = 100 },
+		func(file *undoFile) { file.Revisions[2].Cursors[0].Start.Row = -1 },
+		func(file *undoFile) { file.Revisions[2].Cursors[0].Start.Column = -1 },
+		func(file *undoFile) { file.Revisions[2].Cursors[0].End = file.Revisions[2].Cursors[0].Start },
+	}
+	for _, corrupt := range corruptions {
+		var file undoFile
+		reader, err := os.Open(UndoFilename(filename))
+		assert.Nil(t, err)
+		assert.Nil(t, gob.NewDecoder(reader).Decode(&file))
+		reader.Close()
+		corrupt(&file)
+		corruptFilename := filepath.Join(t.TempDir(), "file.txt")
+		writer, err := os.Create(UndoFilename(corruptFilename))
+		assert.Nil(t, err)
+		assert.Nil(t, gob.NewEncoder(writer).Encode(file))
+		writer.Close()
+
+		loaded := &Editor{Buffer: NewBuffer()}
+		loaded.Buffer.(*BaseBuffer).Current = e.Buffer.(*BaseBuffer).Current
+		assert.Equal(t, ErrUndoFileCorrupt, LoadUndoFile(loaded, corruptFilename))
+		assert.Nil(t, loaded.History.Current)
+	}
+}
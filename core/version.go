@@ -0,0 +1,29 @@
+package core
+
+import "time"
+
+// Hands out increasing versions, so that they never collide and doing the
+// same actions always gives the same versions.
+type VersionAllocator struct {
+	Last Version
+	Now  func() time.Time // Used for timestamps, time.Now if nil
+}
+
+func (a *VersionAllocator) Next() (version Version, timestamp time.Time) {
+	a.Last++
+	return a.Last, a.now()
+}
+
+func (a *VersionAllocator) now() time.Time {
+	if a.Now == nil {
+		return time.Now()
+	}
+	return a.Now()
+}
+
+// Makes sure future versions are greater than version
+func (a *VersionAllocator) Skip(version Version) {
+	if version > a.Last {
+		a.Last = version
+	}
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionAllocator(t *testing.T) {
+	now := time.Date(2022, 3, 17, 0, 0, 0, 0, time.UTC)
+	a := VersionAllocator{Now: func() time.Time { return now }}
+
+	version, timestamp := a.Next()
+	assert.Equal(t, 1, version)
+	assert.Equal(t, now, timestamp)
+
+	version, _ = a.Next()
+	assert.Equal(t, 2, version)
+
+	a.Skip(10)
+	a.Skip(5)
+	version, _ = a.Next()
+	assert.Equal(t, 11, version)
+}
+
+func TestDeterministicVersions(t *testing.T) {
+	versions := func() []Version {
+		e := undoTestEditor()
+		for _, chr := range "abc" {
+			e.MarkUndo()
+			AsEdit(Insert([]rune{chr}))(e)
+		}
+		e.Undo()
+		versions := []Version{}
+		for _, revision := range e.History.Revisions {
+			versions = append(versions, revision.Version)
+		}
+		return versions
+	}
+	assert.Equal(t, []Version{1, 2, 3, 4}, versions())
+	assert.Equal(t, versions(), versions())
+}
@@ -3,24 +3,59 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 )
 
 type flags struct {
-	file string
+	files    []string
+	config   string
+	replay   string
+	record   string
+	script   string
+	commands []string
+}
+
+// Whether the editor runs without a terminal (see runBatch)
+func (f flags) batch() bool {
+	return f.script != "" || len(f.commands) > 0
+}
+
+// A flag that can be given several times
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 func getFlags() (f flags) {
 	help := flag.Bool("help", false, "print help")
+	flag.StringVar(&f.config, "u", configFilename(), "the config file, or NONE to skip it")
+	flag.StringVar(&f.replay, "replay", "", "a file recorded with -record, played before the keys typed")
+	flag.StringVar(&f.record, "record", "", "a file to record the keys typed to")
+	flag.StringVar(&f.script, "s", "", "a file of keys to type without a terminal, written like in :map (- for stdin)")
+	flag.Var((*stringsFlag)(&f.commands), "c", "a command to run without a terminal, before -s (can be repeated)")
 	flag.Parse()
 	if *help {
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
+	// Batch mode only runs the config if asked to, so scripts work the same
+	// for everyone
+	configGiven := false
+	flag.Visit(func(given *flag.Flag) { configGiven = configGiven || given.Name == "u" })
+	if f.batch() && !configGiven {
+		f.config = "NONE"
+	}
 
 	if len(flag.Args()) > 0 {
-		f.file = flag.Args()[0]
+		f.files = flag.Args()
 	} else {
-		f.file = "wr.txt"
+		f.files = []string{"wr.txt"}
 	}
 
 	return f
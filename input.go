@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hhhhhhhhhn/hexes/input"
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// Where the events of the editor come from, like the terminal, a recorded
+// session or the keys of a test
+type InputSource interface {
+	// Waits for the next event. The error is io.EOF after the last one.
+	GetEvent() (*input.Event, error)
+}
+
+// The keys and mouse events of the terminal, which never end
+type terminalInput struct {
+	listener *input.Listener
+}
+
+func newTerminalInput(in io.Reader) *terminalInput {
+	return &terminalInput{listener: input.New(in)}
+}
+
+func (t *terminalInput) GetEvent() (*input.Event, error) {
+	return t.listener.GetEvent(), nil
+}
+
+// Events given beforehand, like the keys of a test
+type scriptInput struct {
+	events []*input.Event
+}
+
+// The keys are written like in :map, so "ihello<Esc>:w<CR>" types and saves
+func newScriptInput(keys string) (*scriptInput, error) {
+	parsed, err := parseKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	script := &scriptInput{}
+	for _, key := range parsed {
+		script.events = append(script.events, &input.Event{EventType: input.KeyPressed, Chr: key})
+	}
+	return script, nil
+}
+
+func (s *scriptInput) GetEvent() (*input.Event, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+// Events saved by recordingInput, one per line (see formatEvent). Empty lines
+// and the ones starting with # are skipped.
+type replayInput struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newReplayInput(in io.Reader) *replayInput {
+	return &replayInput{scanner: bufio.NewScanner(in)}
+}
+
+func (r *replayInput) GetEvent() (*input.Event, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		event, err := parseEvent(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", r.line, err)
+		}
+		return event, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Writes the events of the source as it returns them, so they can be
+// replayed
+type recordingInput struct {
+	source InputSource
+	out    io.Writer
+}
+
+func (r *recordingInput) GetEvent() (*input.Event, error) {
+	event, err := r.source.GetEvent()
+	if err == nil {
+		_, err = fmt.Fprintln(r.out, formatEvent(event))
+	}
+	return event, err
+}
+
+// Returns the events of each source, one after the other
+type chainedInput struct {
+	sources []InputSource
+}
+
+func (c *chainedInput) GetEvent() (*input.Event, error) {
+	for len(c.sources) > 0 {
+		event, err := c.sources[0].GetEvent()
+		if err != io.EOF {
+			return event, err
+		}
+		c.sources = c.sources[1:]
+	}
+	return nil, io.EOF
+}
+
+// Writes a key like in :map, and the rest of events as "mouse", their type
+// and position
+func formatEvent(event *input.Event) string {
+	if event.EventType == input.KeyPressed {
+		return core.FormatKeys([]rune{event.Chr}, keyNames)
+	}
+	return fmt.Sprintf("mouse %v %v %v", event.EventType, event.X, event.Y)
+}
+
+func parseEvent(text string) (*input.Event, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 4 && fields[0] == "mouse" {
+		numbers := []int{}
+		for _, field := range fields[1:] {
+			number, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mouse event %q", text)
+			}
+			numbers = append(numbers, number)
+		}
+		return &input.Event{EventType: input.EventType(numbers[0]), X: numbers[1], Y: numbers[2]}, nil
+	}
+	keys, err := core.ParseKeys(text, keyNames, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("%q is not a single key", text)
+	}
+	return &input.Event{EventType: input.KeyPressed, Chr: keys[0]}, nil
+}
+
+// Returns the input of the terminal, after the events of the replay file if
+// given, and recording them to the record file if given
+func terminalSource(replay, record string) (InputSource, error) {
+	var source InputSource = newTerminalInput(os.Stdin)
+	if replay != "" {
+		file, err := os.Open(replay)
+		if err != nil {
+			return nil, err
+		}
+		source = &chainedInput{sources: []InputSource{newReplayInput(file), source}}
+	}
+	if record != "" {
+		file, err := os.Create(record)
+		if err != nil {
+			return nil, err
+		}
+		source = &recordingInput{source: source, out: file}
+	}
+	return source, nil
+}
+
+// Returned by runInput when the input ends without quitting
+var errInputEnded = errors.New("the input ended")
+
+// Why the input ended, nil until then. Once it ends, nextEvent only returns
+// escapes, which leave the modes, and normal mode returns (see runInput).
+var inputEnd error
+// The status when the input ended, as leaving the modes changes it
+var inputEndText string
+var inputEndOk bool
+
+// Ends the input with the error, like when it runs out or a command fails in
+// batch mode. Only the first error is kept.
+func endInput(err error) {
+	if inputEnd == nil {
+		inputEnd = err
+		inputEndText, inputEndOk = statusText, statusOk
+	}
+}
+
+// The events of the input, read in the background so that jobs can update
+// the screen while waiting for them. It is closed when the input ends, after
+// setting inputErr.
+var inputEvents chan *input.Event
+var inputErr error
+
+func readInput(source InputSource) {
+	events := make(chan *input.Event)
+	inputEvents = events
+	go func() {
+		defer close(events)
+		for {
+			event, err := source.GetEvent()
+			if err != nil {
+				inputErr = err
+				return
+			}
+			events <- event
+		}
+	}()
+}
+
+// Runs the editor in normal mode with the input, until it ends. The error is
+// errInputEnded, or the one of the input if it failed. The status is the one
+// shown when it ended.
+func runInput(source InputSource) error {
+	readInput(source)
+	inputEnd = nil
+	normalMode()
+	err := inputEnd
+	// The rest of a macro being played is not done
+	inputEnd, playback = nil, nil
+	statusText, statusOk = inputEndText, inputEndOk
+	renderer.ChangeStatus(statusText, statusOk)
+	return err
+}
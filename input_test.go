@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hhhhhhhhhn/hexes/input"
+	"github.com/hhhhhhhhhn/wr/advancedtui"
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// Opens a file with the text, without a terminal
+func startSession(t *testing.T, text string) {
+	renderer = advancedtui.NewHeadlessTui(io.Discard, 24, 80)
+	openBuffers, currentBuffer, editor = nil, 0, nil
+	playback, noremapEvents, inputEnd = nil, map[*input.Event]bool{}, nil
+	eventIndex, latestEvent = -1, -1
+	globalOptions = core.OptionValues{}
+	for _, modeMappings := range mappings {
+		for _, keys := range modeMappings.Keys() {
+			modeMappings.Unbind(keys)
+		}
+	}
+	filename := filepath.Join(t.TempDir(), "test.txt")
+	assert.Nil(t, os.WriteFile(filename, []byte(text), 0644))
+	assert.Nil(t, openFile(filename))
+	switchBuffer(0)
+}
+
+// Types the keys, written like in :map, in normal mode
+func typeKeys(t *testing.T, keys string) {
+	script, err := newScriptInput(keys)
+	assert.Nil(t, err)
+	assert.Equal(t, errInputEnded, runInput(script))
+}
+
+func bufferText() string {
+	lines := []string{}
+	for i := 0; i < editor.Buffer.GetLength(); i++ {
+		lines = append(lines, string(editor.Buffer.GetLine(i)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns the start of the main cursor
+func mainCursor() core.Location {
+	return editor.Cursors[len(editor.Cursors)-1].Start
+}
+
+func TestSessionEditing(t *testing.T) {
+	startSession(t, "hello world\nsecond line\n")
+	typeKeys(t, "dwjx")
+	assert.Equal(t, "world\necond line", bufferText())
+	assert.Equal(t, core.Location{Row: 1, Column: 0}, mainCursor())
+
+	typeKeys(t, "Athe end<Esc>gg0ithe start <Esc>")
+	assert.Equal(t, "the start world\necond linethe end", bufferText())
+
+	typeKeys(t, "uu")
+	assert.Equal(t, "world\necond line", bufferText())
+
+	typeKeys(t, ":s/o/0/g<CR>G")
+	assert.Equal(t, "world\nec0nd line", bufferText())
+	assert.Equal(t, core.Location{Row: 1, Column: 0}, mainCursor())
+	assert.True(t, statusOk)
+}
+
+func TestSessionScreen(t *testing.T) {
+	startSession(t, "hello\n")
+	typeKeys(t, ":set number<CR>x")
+	screen := renderer.Screen()
+	assert.Equal(t, 24, len(screen))
+	assert.Equal(t, "1 ello", strings.TrimRight(screen[0], " "))
+	assert.Contains(t, screen[23], "line 0, col 0")
+}
+
+func TestSessionWrap(t *testing.T) {
+	long := strings.Repeat("a", 70) + strings.Repeat("b", 30)
+	startSession(t, long + "\nshort\n")
+	typeKeys(t, "x")
+	screen := renderer.Screen()
+	assert.Equal(t, strings.Repeat("a", 69) + strings.Repeat("b", 11), screen[0])
+	assert.Equal(t, strings.Repeat("b", 19), strings.TrimRight(screen[1], " "))
+	assert.Equal(t, "short", strings.TrimRight(screen[2], " "))
+
+	typeKeys(t, ":set nowrap<CR>")
+	screen = renderer.Screen()
+	assert.Equal(t, "short", strings.TrimRight(screen[1], " "))
+	output, _ := runCommand("set")
+	assert.Equal(t, "nowrap", output)
+
+	// Scrolling counts the rows of the wrapped lines
+	startSession(t, strings.Repeat(long + "\n", 29) + strings.Repeat("z", 100) + "\n")
+	typeKeys(t, "G")
+	screen = renderer.Screen()
+	assert.Equal(t, strings.Repeat("z", 80), screen[20])
+	assert.Equal(t, strings.Repeat("z", 20), strings.TrimRight(screen[21], " "))
+}
+
+func TestSessionCursors(t *testing.T) {
+	startSession(t, "a\nb\nc\nd\n")
+	typeKeys(t, "<C-v>jj<Esc>iX<Esc>")
+	assert.Equal(t, "Xa\nXb\nXc\nd", bufferText())
+	assert.Equal(t, 3, len(editor.Cursors))
+
+	typeKeys(t, "<Esc>G.")
+	assert.Equal(t, "Xa\nXb\nXc\nXd", bufferText())
+	assert.Equal(t, 1, len(editor.Cursors))
+}
+
+func TestSessionRepeatCount(t *testing.T) {
+	// The count replaces the one of the movement
+	startSession(t, "one two three four five six\n")
+	typeKeys(t, "d2w")
+	assert.Equal(t, "three four five six", bufferText())
+	typeKeys(t, "3.")
+	assert.Equal(t, "six", bufferText())
+	// It is a single undo step
+	typeKeys(t, "u")
+	assert.Equal(t, "three four five six", bufferText())
+	// And is kept for the next
+	typeKeys(t, ".")
+	assert.Equal(t, "six", bufferText())
+
+	// The rest are repeated
+	startSession(t, "abcdef\n")
+	typeKeys(t, "x3.")
+	assert.Equal(t, "ef", bufferText())
+	typeKeys(t, "i-<Esc>2.")
+	assert.Equal(t, "---ef", bufferText())
+}
+
+func TestSessionMacros(t *testing.T) {
+	startSession(t, "one a\ntwo b\nthree c\nfour d\n")
+	typeKeys(t, "qadw0jq2@a")
+	assert.Equal(t, "a\nb\nc\nfour d", bufferText())
+	assert.Equal(t, core.Location{Row: 3, Column: 0}, mainCursor())
+	typeKeys(t, ":%normal A!<CR>")
+	assert.Equal(t, "a!\nb!\nc!\nfour d!", bufferText())
+}
+
+func TestSessionMappings(t *testing.T) {
+	startSession(t, "one two three\nfour five\n")
+	// The keys of the command are typed, so < is written as <lt>
+	typeKeys(t, ":nnoremap <lt>Space>d dw<CR><Space>d")
+	assert.Equal(t, "two three\nfour five", bufferText())
+
+	// The mapping of x is not used by :noremap
+	typeKeys(t, ":map x j<CR>:noremap X x<CR>X")
+	assert.Equal(t, "wo three\nfour five", bufferText())
+	typeKeys(t, ":map Y x<CR>Y")
+	assert.Equal(t, core.Location{Row: 1, Column: 0}, mainCursor())
+
+	typeKeys(t, ":imap jk <lt>Esc><CR>ggijjk")
+	assert.Equal(t, "jwo three\nfour five", bufferText())
+
+	typeKeys(t, ":set leader=,<CR>:nmap <lt>Leader>e A!<lt>Esc><CR>,e")
+	assert.Equal(t, "jwo three!\nfour five", bufferText())
+
+	typeKeys(t, ":map a b<CR>:map b a<CR>a")
+	assert.Equal(t, "recursive mapping", statusText)
+	assert.False(t, statusOk)
+
+	typeKeys(t, ":unmap x<CR>:unmap x<CR>")
+	assert.Equal(t, "no mapping for x", statusText)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	script, err := newScriptInput("ia <Esc>:w<CR><C-x>v<Up>")
+	assert.Nil(t, err)
+	recorded := &bytes.Buffer{}
+	recording := &recordingInput{source: &chainedInput{sources: []InputSource{
+		script,
+		&scriptInput{events: []*input.Event{{EventType: input.MouseLeftClick, X: 3, Y: 4}}},
+	}}, out: recorded}
+	events := []*input.Event{}
+	for {
+		event, err := recording.GetEvent()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		events = append(events, event)
+	}
+	assert.Equal(t, "i\na\n<Space>\n<Esc>\n:\nw\n<CR>\n<C-x>\nv\n<Up>\nmouse 2 3 4\n", recorded.String())
+
+	replay := newReplayInput(strings.NewReader("# a comment\n\n" + recorded.String()))
+	for _, event := range events {
+		replayed, err := replay.GetEvent()
+		assert.Nil(t, err)
+		assert.Equal(t, event, replayed)
+	}
+	_, err = replay.GetEvent()
+	assert.Equal(t, io.EOF, err)
+
+	replay = newReplayInput(strings.NewReader("i\nab\n"))
+	replay.GetEvent()
+	_, err = replay.GetEvent()
+	assert.Equal(t, `line 2: "ab" is not a single key`, err.Error())
+}
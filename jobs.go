@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/hhhhhhhhhn/wr/advancedtui"
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/hhhhhhhhhn/wr/treesitter"
+)
+
+// A shell command run in the background with :!!, whose output is shown in a
+// read-only buffer as it arrives (see checkChangeable)
+type job struct {
+	id       int
+	command  string
+	process  *exec.Cmd
+	output   *openBuffer
+	running  bool
+	exitCode int
+	onFinish func(*job) // Can be nil
+}
+
+var jobs []*job
+
+// Functions sent by the jobs, run by the input loop while it waits (see
+// nextEvent), as the buffers and the screen are not safe to use from other
+// goroutines
+var jobUpdates = make(chan func())
+
+var errJobOutput = errors.New("the output of a job can't be saved")
+var errReadOnly = errors.New("the output of a job is read-only")
+
+// Returns errReadOnly if the current buffer is the output of a job, which
+// only the job changes. It is checked before changing the buffer.
+func checkChangeable() error {
+	if len(openBuffers) > 0 && current().job != nil {
+		return errReadOnly
+	}
+	return nil
+}
+
+// Like checkChangeable, for the actions of the modes, which show the error
+func changeable() bool {
+	if err := checkChangeable(); err != nil {
+		statusText, statusOk = err.Error(), false
+		renderer.ChangeStatus(statusText, statusOk)
+		return false
+	}
+	return true
+}
+
+// Runs the shell command in the background, switching to its output. The
+// function is called when it ends, if not nil.
+func startJob(command string, onFinish func(*job)) (string, bool) {
+	if strings.TrimSpace(command) == "" {
+		return "please provide a command", false
+	}
+	j := &job{id: len(jobs) + 1, command: command, running: true, onFinish: onFinish}
+	j.process = exec.Command("/bin/sh", "-c", command)
+	// In its own process group, so cancelling it also stops the processes
+	// it started
+	j.process.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err.Error(), false
+	}
+	j.process.Stdout = writer
+	j.process.Stderr = writer
+	err = j.process.Start()
+	writer.Close()
+	if err != nil {
+		reader.Close()
+		return err.Error(), false
+	}
+
+	jobs = append(jobs, j)
+	j.output = newOutputBuffer(fmt.Sprintf("[job %v] %v", j.id, command), j)
+	openBuffers = append(openBuffers, j.output)
+	switchBuffer(len(openBuffers) - 1)
+	go j.read(reader)
+	return j.status(), true
+}
+
+// Sends the output to the input loop as it arrives, and then the exit code
+func (j *job) read(reader *os.File) {
+	defer reader.Close()
+	chunk := make([]byte, 4096)
+	pending := []byte{}
+	for {
+		n, err := reader.Read(chunk)
+		text := append(pending, chunk[:n]...)
+		// A character split between reads waits for the rest of it
+		complete := len(text)
+		for i := len(text) - 1; i >= 0 && i >= len(text) - utf8.UTFMax; i-- {
+			if utf8.RuneStart(text[i]) {
+				if !utf8.FullRune(text[i:]) {
+					complete = i
+				}
+				break
+			}
+		}
+		pending = append([]byte{}, text[complete:]...)
+		if complete > 0 {
+			output := string(text[:complete])
+			jobUpdates <- func() { j.write(output) }
+		}
+		if err != nil {
+			break
+		}
+	}
+	j.process.Wait()
+	exitCode := j.process.ProcessState.ExitCode()
+	jobUpdates <- func() { j.finish(exitCode) }
+}
+
+// Adds the text to the end of the output. Cursors in the last line follow
+// it.
+func (j *job) write(text string) {
+	editor := j.output.editor
+	buffer := j.output.buffer
+
+	last := buffer.GetLength() - 1
+	lines := strings.Split(strings.ReplaceAll(text, "\r", ""), "\n")
+	buffer.ChangeLine(last, core.Join(buffer.GetLine(last), []rune(lines[0])))
+	for _, line := range lines[1:] {
+		buffer.AddLine(buffer.GetLength(), []rune(line))
+	}
+	for _, cursor := range editor.Cursors {
+		if cursor.Start.Row == last && cursor.End.Row == last {
+			cursor.Range = core.Range{
+				Start: core.Location{Row: buffer.GetLength() - 1, Column: 0},
+				End:   core.Location{Row: buffer.GetLength() - 1, Column: 1},
+			}
+		}
+	}
+}
+
+func (j *job) finish(exitCode int) {
+	j.running = false
+	j.exitCode = exitCode
+	buffer := j.output.buffer
+	last := buffer.GetLength() - 1
+	// The last newline doesn't start a line
+	if last > 0 && len(buffer.GetLine(last)) == 0 {
+		buffer.RemoveLine(last)
+		for _, cursor := range j.output.editor.Cursors {
+			if cursor.End.Row == last {
+				cursor.Range = core.Range{
+					Start: core.Location{Row: last - 1, Column: 0},
+					End:   core.Location{Row: last - 1, Column: 1},
+				}
+			}
+		}
+	}
+	statusText, statusOk = j.status(), exitCode == 0
+	if j.onFinish != nil {
+		j.onFinish(j)
+	}
+	renderer.ChangeStatus(statusText, statusOk)
+}
+
+func (j *job) outputLines() []string {
+	buffer := j.output.editor.Buffer
+	lines := make([]string, buffer.GetLength())
+	for row := range lines {
+		lines[row] = string(buffer.GetLine(row))
+	}
+	return lines
+}
+
+// Stops the job and the processes it started
+func (j *job) cancel() {
+	if j.running {
+		syscall.Kill(-j.process.Process.Pid, syscall.SIGTERM)
+	}
+}
+
+func (j *job) status() string {
+	state := "running"
+	if !j.running && j.exitCode < 0 {
+		state = "killed"
+	} else if !j.running {
+		state = fmt.Sprintf("exited with %v", j.exitCode)
+	}
+	return fmt.Sprintf("job %v (%v) %v", j.id, j.command, state)
+}
+
+func runningJobs() int {
+	running := 0
+	for _, j := range jobs {
+		if j.running {
+			running++
+		}
+	}
+	return running
+}
+
+func jobsStatus() string {
+	switch running := runningJobs(); running {
+	case 0:
+		return ""
+	case 1:
+		return " (1 job)"
+	default:
+		return fmt.Sprintf(" (%v jobs)", running)
+	}
+}
+
+func listJobs() (string, bool) {
+	if len(jobs) == 0 {
+		return "no jobs", true
+	}
+	list := []string{}
+	for _, j := range jobs {
+		list = append(list, j.status())
+	}
+	return strings.Join(list, " | "), true
+}
+
+// Cancels the job with the id in the arguments, or the one of the current
+// buffer, or the last one running
+func killJob(args []string) (string, bool) {
+	var target *job
+	if len(args) > 1 {
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id < 1 || id > len(jobs) {
+			return "no job " + args[1], false
+		}
+		target = jobs[id - 1]
+	} else if len(openBuffers) > 0 && current().job != nil {
+		target = current().job
+	} else {
+		for _, j := range jobs {
+			if j.running {
+				target = j
+			}
+		}
+	}
+	if target == nil || !target.running {
+		return "no job running", false
+	}
+	target.cancel()
+	return "cancelled job " + strconv.Itoa(target.id), true
+}
+
+func newOutputBuffer(name string, j *job) *openBuffer {
+	lang, _ := treesitter.GetLanguage("c")
+	buffer := treesitter.NewBuffer(*lang)
+	core.LoadLines(buffer, [][]rune{{}})
+
+	editor := &core.Editor{
+		Buffer: buffer,
+		Config: editorConfig,
+	}
+	core.SetCursors(0, 0, 0, 1)(editor)
+
+	return &openBuffer{
+		filename: name,
+		editor: editor,
+		buffer: buffer,
+		syntaxProvider: &advancedtui.NoHighlight{},
+		job: j,
+		options: newBufferOptions(editor),
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs the updates of the jobs until they end
+func waitForJobs() {
+	for runningJobs() > 0 {
+		(<-jobUpdates)()
+	}
+}
+
+func TestJobOutput(t *testing.T) {
+	startSession(t, "text\n")
+	output, ok := startJob(`printf 'one\ntw'; sleep 0.1; printf 'o\n'`, nil)
+	assert.True(t, ok)
+	j := current().job
+	assert.Equal(t, j.status(), output)
+	assert.Contains(t, output, "running")
+	waitForJobs()
+	assert.Equal(t, "one\ntwo", bufferText())
+	assert.Contains(t, j.status(), "exited with 0")
+	assert.Equal(t, j.status(), statusText)
+	assert.True(t, statusOk)
+
+	// A character split between reads waits for the rest of it
+	startJob(`printf '\303'; sleep 0.1; printf '\251\n'; exit 3`, nil)
+	waitForJobs()
+	assert.Equal(t, "é", bufferText())
+	assert.Equal(t, 3, current().job.exitCode)
+	assert.False(t, statusOk)
+}
+
+func TestJobFollowsOutput(t *testing.T) {
+	startSession(t, "")
+	startJob(`echo one; sleep 0.1; echo two; sleep 0.1; echo three`, nil)
+	waitForJobs()
+	assert.Equal(t, 2, mainCursor().Row)
+}
+
+func TestJobCancel(t *testing.T) {
+	startSession(t, "text\n")
+	finished := false
+	startJob("sleep 10", func(*job) { finished = true })
+	j := current().job
+	output, ok := killJob([]string{"kill"})
+	assert.True(t, ok)
+	assert.Equal(t, fmt.Sprintf("cancelled job %v", j.id), output)
+	waitForJobs()
+	assert.True(t, finished)
+	assert.False(t, j.running)
+	assert.Contains(t, j.status(), "killed")
+
+	_, ok = killJob([]string{"kill"})
+	assert.False(t, ok)
+}
+
+func TestJobOutputReadOnly(t *testing.T) {
+	startSession(t, "")
+	startJob("printf 'a\nb\n'", nil)
+	waitForJobs()
+	for _, keys := range []string{"x", "dw", "ifoo<Esc>", "pa", "u", ":s/a/c/<CR>", ":%d<CR>", ":g/a/d<CR>", ":%!sort -r<CR>", ":m$<CR>"} {
+		statusText, statusOk = "", true
+		typeKeys(t, keys)
+		assert.Equal(t, "a\nb", bufferText(), keys)
+		assert.Equal(t, errReadOnly.Error(), statusText, keys)
+		assert.False(t, statusOk, keys)
+	}
+	// Moving and yanking is allowed
+	typeKeys(t, "j:y<CR>")
+	assert.True(t, statusOk)
+	assert.Equal(t, 1, mainCursor().Row)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhhhhhhhhn/hexes/input"
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// The names of the special keys, written like <Esc> (see core.ParseKeys)
+var keyNames = map[string]rune{
+	"Esc":      input.ESCAPE,
+	"CR":       input.ENTER,
+	"Enter":    input.ENTER,
+	"Tab":      input.TAB,
+	"BS":       input.BACKSPACE,
+	"Space":    ' ',
+	"lt":       '<',
+	"Up":       input.KEY_UP,
+	"Down":     input.KEY_DOWN,
+	"Left":     input.KEY_LEFT,
+	"Right":    input.KEY_RIGHT,
+	"Insert":   input.KEY_INSERT,
+	"Del":      input.KEY_DELETE,
+	"Home":     input.KEY_HOME,
+	"End":      input.KEY_END,
+	"PageUp":   input.KEY_PAGE_UP,
+	"PageDown": input.KEY_PAGE_DOWN,
+	"F1":       input.KEY_F1,
+	"F2":       input.KEY_F2,
+	"F3":       input.KEY_F3,
+	"F4":       input.KEY_F4,
+	"F5":       input.KEY_F5,
+	"F6":       input.KEY_F6,
+	"F7":       input.KEY_F7,
+	"F8":       input.KEY_F8,
+	"F9":       input.KEY_F9,
+	"F10":      input.KEY_F10,
+	"F11":      input.KEY_F11,
+	"F12":      input.KEY_F12,
+}
+
+// Returns a keymap with the bindings, where the keys are written like in
+// :map. The later ones replace the earlier.
+func keymapOf[T any](bindings ...map[string]T) *core.Keymap[T] {
+	keymap := core.NewKeymap[T]()
+	for _, keysBindings := range bindings {
+		for text, value := range keysBindings {
+			keys, err := core.ParseKeys(text, keyNames, nil)
+			if err != nil {
+				panic(err)
+			}
+			keymap.Bind(keys, value)
+		}
+	}
+	return keymap
+}
+
+// Keys that are replaced by others, with :map
+type mapping struct {
+	keys  []rune
+	remap bool // Whether the keys can be mapped again, or only do what they do by default
+}
+
+// The mappings of each mode
+var mappings = map[string]*core.Keymap[mapping]{
+	"normal":     core.NewKeymap[mapping](),
+	"visual":     core.NewKeymap[mapping](),
+	"new cursor": core.NewKeymap[mapping](),
+	"insert":     core.NewKeymap[mapping](),
+	"command":    core.NewKeymap[mapping](),
+}
+
+// The modes of each prefix of :map, :noremap and :unmap, like :nmap for
+// normal mode. Without one, they are the modes with movements.
+var mapModes = map[string][]string{
+	"":  {"normal", "visual", "new cursor"},
+	"n": {"normal"},
+	"v": {"visual"},
+	"m": {"new cursor"},
+	"i": {"insert"},
+	"c": {"command"},
+}
+
+// So that the keys read again (see unGetEvent) fit in the events
+const maxMappingKeys = 16
+
+// Stops mappings that map themselves, which would never end
+const maxMappingDepth = 1000
+
+// The mappings done since the last keys that were not mapped
+var mappingDepth = 0
+
+type keysRead int
+
+const (
+	unmatched keysRead = iota // The keys matched nothing, and the first one is left to be read
+	mapped                    // The keys matched a mapping, and were replaced by its keys
+	bound                     // The keys matched a binding of the mode
+)
+
+// Reads keys until they match a mapping of the mode or one of its bindings,
+// and returns the binding. The keys that are the start of a longer mapping
+// wait for the next one until 'timeoutlen', while those of a binding wait
+// forever. The keys after the match are left to be read. A count typed
+// before a mapping is put before its keys.
+func readKeys[T any](mode string, bindings *core.Keymap[T], count int) (value T, read keysRead) {
+	modeMappings := mappings[mode]
+	keys := []rune{}
+	var foundMapping mapping
+	var foundValue T
+	read = unmatched
+	readEvents, foundEvents := 0, 0
+	remap, waitMapping := true, false
+	for {
+		var event *input.Event
+		if waitMapping && options.Bool(globalOptions, "timeout") {
+			event = getEventBefore(time.After(time.Duration(options.Int(globalOptions, "timeoutlen")) * time.Millisecond))
+		} else {
+			event = getEvent()
+		}
+		if event == nil {
+			if read != unmatched || !bindings.HasLonger(keys) {
+				break
+			}
+			// Only the bindings are waited for after the timeout
+			remap, waitMapping = false, false
+			continue
+		}
+		readEvents++
+		if event.EventType != input.KeyPressed {
+			if len(keys) == 0 {
+				break
+			}
+			continue
+		}
+		if len(keys) == 0 {
+			remap = !eventsNoremap[eventIndex % eventsLength]
+		}
+		keys = append(keys, event.Chr)
+		if keysMapping, ok := modeMappings.Get(keys); ok && remap {
+			foundMapping, read, foundEvents = keysMapping, mapped, readEvents
+		} else if binding, ok := bindings.Get(keys); ok {
+			foundValue, read, foundEvents = binding, bound, readEvents
+		}
+		waitMapping = remap && modeMappings.HasLonger(keys)
+		if !waitMapping && !bindings.HasLonger(keys) {
+			break
+		}
+	}
+	for i := foundEvents; i < readEvents; i++ {
+		unGetEvent()
+	}
+
+	switch read {
+	case mapped:
+		expandMapping(foundMapping, count)
+	case bound:
+		mappingDepth = 0
+		value = foundValue
+	default:
+		mappingDepth = 0
+	}
+	return value, read
+}
+
+// Puts the keys of the mapping before the ones left to be read
+func expandMapping(m mapping, count int) {
+	mappingDepth++
+	if mappingDepth > maxMappingDepth {
+		mappingDepth = 0
+		playback = nil
+		noremapEvents = map[*input.Event]bool{}
+		latestEvent = eventIndex
+		statusText, statusOk = "recursive mapping", false
+		renderer.ChangeStatus(statusText, statusOk)
+		return
+	}
+	keys := m.keys
+	if count > 1 {
+		keys = append([]rune(strconv.Itoa(count)), keys...)
+	}
+	expanded := []*input.Event{}
+	for _, key := range keys {
+		event := &input.Event{EventType: input.KeyPressed, Chr: key}
+		if !m.remap {
+			noremapEvents[event] = true
+		}
+		expanded = append(expanded, event)
+	}
+	playback = append(append(expanded, takeUnread()...), playback...)
+}
+
+// Takes out the events left to be read again (see unGetEvent), so that
+// others can be played before them
+func takeUnread() []*input.Event {
+	unread := []*input.Event{}
+	for i := eventIndex + 1; i <= latestEvent; i++ {
+		event := events[i % eventsLength]
+		if eventsNoremap[i % eventsLength] {
+			noremapEvents[event] = true
+		}
+		unread = append(unread, event)
+	}
+	latestEvent = eventIndex
+	return unread
+}
+
+// Parses keys written like in :map, with the leader of the option
+func parseKeys(text string) ([]rune, error) {
+	leader, _ := core.ParseKeys(options.String(globalOptions, "leader"), keyNames, nil)
+	return core.ParseKeys(text, keyNames, leader)
+}
+
+func init() {
+	for prefix, modes := range mapModes {
+		commands[prefix + "map"] = mapCommand(modes, true)
+		commands[prefix + "noremap"] = mapCommand(modes, false)
+		commands[prefix + "unmap"] = unmapCommand(modes)
+	}
+}
+
+// Maps the keys of the first argument to the rest in each mode. Without the
+// rest, the mappings of the keys are shown, and without arguments, all of
+// them.
+func mapCommand(modes []string, remap bool) func([]string) (string, bool) {
+	return func(args []string) (string, bool) {
+		if len(args) < 2 || args[1] == "" {
+			return listMappings(modes, nil)
+		}
+		keys, err := parseKeys(args[1])
+		if err != nil {
+			return err.Error(), false
+		}
+		if len(keys) == 0 {
+			return "please provide the keys", false
+		}
+		if len(keys) > maxMappingKeys {
+			return fmt.Sprintf("mappings can't have more than %v keys", maxMappingKeys), false
+		}
+		if len(args) == 2 {
+			return listMappings(modes, keys)
+		}
+		to, err := parseKeys(strings.Join(args[2:], " "))
+		if err != nil {
+			return err.Error(), false
+		}
+		for _, mode := range modes {
+			mappings[mode].Bind(keys, mapping{keys: to, remap: remap})
+		}
+		return "", true
+	}
+}
+
+func unmapCommand(modes []string) func([]string) (string, bool) {
+	return func(args []string) (string, bool) {
+		if len(args) < 2 {
+			return "please provide the keys", false
+		}
+		keys, err := parseKeys(args[1])
+		if err != nil {
+			return err.Error(), false
+		}
+		removed := false
+		for _, mode := range modes {
+			if mappings[mode].Unbind(keys) {
+				removed = true
+			}
+		}
+		if !removed {
+			return "no mapping for " + args[1], false
+		}
+		return "", true
+	}
+}
+
+// Shows the mappings of the modes like "n gx *dd", with the prefix of the
+// mode, and * for the ones that are not mapped again. If keys is not nil,
+// only its mappings are shown.
+func listMappings(modes []string, keys []rune) (string, bool) {
+	list := []string{}
+	for _, mode := range modes {
+		prefix := ""
+		for otherPrefix, otherModes := range mapModes {
+			if len(otherModes) == 1 && otherModes[0] == mode {
+				prefix = otherPrefix
+			}
+		}
+		sequences := mappings[mode].Keys()
+		if keys != nil {
+			sequences = [][]rune{keys}
+		}
+		for _, mapped := range sequences {
+			m, ok := mappings[mode].Get(mapped)
+			if !ok {
+				continue
+			}
+			noremap := ""
+			if !m.remap {
+				noremap = "*"
+			}
+			list = append(list, prefix + " " + core.FormatKeys(mapped, keyNames) + " " + noremap + core.FormatKeys(m.keys, keyNames))
+		}
+	}
+	switch {
+	case len(list) > 0:
+		return strings.Join(list, " | "), true
+	case keys != nil:
+		return "no mapping for " + core.FormatKeys(keys, keyNames), false
+	}
+	return "no mappings", true
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hhhhhhhhhn/hexes/input"
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+var macros [core.RegisterCount][]*input.Event
+var recording = -1 // The register being recorded to, or -1
+var lastMacro = -1
+// Events waiting to be returned before reading from the input
+var playback []*input.Event
+// The events of playback that come from a :noremap, which are not mapped
+// again
+var noremapEvents = map[*input.Event]bool{}
+
+// Returns the next event, from a macro being played or from the input. Only
+// the events from the input are recorded, so playing a macro while recording
+// records the keys that played it. While waiting, the updates of the jobs are
+// done, and nil is returned if the timeout comes first. Once the input ends,
+// escapes are returned, so the modes are left (see endInput).
+func nextEvent(timeout <-chan time.Time) *input.Event {
+	if inputEnd == nil && len(playback) > 0 {
+		event := playback[0]
+		playback = playback[1:]
+		return event
+	}
+	for inputEnd == nil {
+		select {
+		case event, ok := <-inputEvents:
+			if ok {
+				if recording >= 0 {
+					macros[recording] = append(macros[recording], event)
+				}
+				return event
+			}
+			// The keys waiting for a longer mapping are used
+			if timeout != nil {
+				return nil
+			}
+			if inputErr == io.EOF {
+				endInput(errInputEnded)
+			} else {
+				endInput(inputErr)
+			}
+		case update := <-jobUpdates:
+			update()
+			redraw()
+		case <-timeout:
+			return nil
+		}
+	}
+	return &input.Event{EventType: input.KeyPressed, Chr: input.ESCAPE}
+}
+
+// Starts recording to the register, or stops if already recording
+func toggleRecording() {
+	if recording >= 0 {
+		macro := macros[recording]
+		// The q that stopped the recording is not part of it
+		if len(macro) > 0 && macro[len(macro)-1] == events[eventIndex % eventsLength] {
+			macros[recording] = macro[:len(macro)-1]
+		}
+		recording = -1
+		updateStatusText()
+		return
+	}
+	recording = getRegister()
+	if recording >= 0 {
+		macros[recording] = nil
+	}
+	updateStatusText()
+}
+
+// Plays the macro in the register the amount of times. The events are
+// played before any other pending ones, so macros can call other macros.
+func playMacro(register int, times int) {
+	if register < 0 {
+		return
+	}
+	lastMacro = register
+	events := []*input.Event{}
+	for i := 0; i < times; i++ {
+		events = append(events, macros[register]...)
+	}
+	playback = append(events, playback...)
+}
+
+func recordingStatus() string {
+	if recording < 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (recording @%c)", 'a' + recording)
+}
+
+// Does the keys in normal mode as if they were typed, returning once they
+// are used or the input ends. An escape is added at the end, to leave insert
+// mode or cancel an unfinished action.
+func runNormal(keys string) {
+	pending := len(playback)
+	events := []*input.Event{}
+	for _, chr := range keys + string(input.ESCAPE) {
+		events = append(events, &input.Event{EventType: input.KeyPressed, Chr: chr})
+	}
+	playback = append(events, playback...)
+	lastCursor := defaultCursor
+	for inputEnd == nil && (len(playback) > pending || eventIndex < latestEvent) {
+		normalAction(&lastCursor)
+	}
+}
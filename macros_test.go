@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hhhhhhhhhn/hexes/input"
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// Types the keys, with a mouse event between each part
+func typeWithMouse(t *testing.T, parts ...string) {
+	script := &scriptInput{}
+	for i, keys := range parts {
+		if i > 0 {
+			script.events = append(script.events, &input.Event{EventType: input.MouseMove, X: 3, Y: 1})
+		}
+		part, err := newScriptInput(keys)
+		assert.Nil(t, err)
+		script.events = append(script.events, part.events...)
+	}
+	assert.Equal(t, errInputEnded, runInput(script))
+}
+
+func TestMarkSkipsMouse(t *testing.T) {
+	startSession(t, "a\nb\nc\n")
+	typeWithMouse(t, "jm", "xG:'x<CR>")
+	assert.Equal(t, core.Location{Row: 1, Column: 0}, mainCursor())
+	assert.Equal(t, "a\nb\nc", bufferText())
+}
+
+func TestMacroCounts(t *testing.T) {
+	startSession(t, "1\n2\n3\n4\n5\n6\n")
+	typeKeys(t, "qbxjq")
+	assert.Equal(t, "\n2\n3\n4\n5\n6", bufferText())
+	typeKeys(t, "2@b")
+	assert.Equal(t, "\n\n\n4\n5\n6", bufferText())
+	// @@ plays the last one
+	typeKeys(t, "@@")
+	assert.Equal(t, "\n\n\n\n5\n6", bufferText())
+	typeWithMouse(t, "@", "b")
+	assert.Equal(t, "\n\n\n\n\n6", bufferText())
+	assert.Equal(t, 5, mainCursor().Row)
+}
+
+func TestMacroRecordsPlayedKeys(t *testing.T) {
+	startSession(t, "abcdef\n")
+	typeKeys(t, "qaxq")
+	// Playing a macro while recording records the keys that played it
+	typeKeys(t, "qb@axq")
+	assert.Equal(t, "def", bufferText())
+	assert.Equal(t, 3, len(macros['b' - 'a']))
+	typeKeys(t, "@b")
+	assert.Equal(t, "f", bufferText())
+	assert.Equal(t, -1, recording)
+}
+
+func TestInvalidRegister(t *testing.T) {
+	startSession(t, "abc\n")
+	// <BS> is past the last register, so nothing is recorded, yanked or pasted
+	typeKeys(t, "q<BS>xq<BS>")
+	assert.Equal(t, -1, recording)
+	assert.Equal(t, "bc", bufferText())
+	typeKeys(t, "@<BS>y<BS>p<BS>")
+	assert.Equal(t, "bc", bufferText())
+}
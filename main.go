@@ -1,13 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"os"
-	"regexp"
 	"runtime"
 	"runtime/pprof"
 	"strings"
 
-	"github.com/hhhhhhhhhn/hexes/input"
 	"github.com/hhhhhhhhhn/hexes"
 	"github.com/hhhhhhhhhn/wr/core"
 	"github.com/hhhhhhhhhn/wr/tui"
@@ -15,13 +14,14 @@ import (
 	"github.com/hhhhhhhhhn/wr/advancedtui"
 )
 
-var scroll = 0
 var editor         *core.Editor
 var renderer       *advancedtui.Tui
-var listener       *input.Listener
 var buffer         *treesitter.Buffer
 var syntaxProvider advancedtui.SyntaxProvider
 
+// Shown instead of the mode when starting
+var startupErrors []string
+
 var _ tui.Renderer = renderer
 var _ core.Buffer = buffer
 
@@ -47,55 +47,71 @@ func toggleCpuProf() {
 
 func main() {
 	f := getFlags()
-	lang, _ := treesitter.GetLanguage("c")
-	buffer = treesitter.NewBuffer(*lang)
-	loadBuffer(f.file, buffer)
-	editor = &core.Editor{
-		Buffer: buffer,
-		Config: core.EditorConfig{Tabsize: 4},
-		Global: map[string]any{
-			"Regex": regexp.MustCompile(`^\s(?P<Cursor>)\S`),
-			"Filename": f.file,
-		},
+	if f.batch() {
+		// It only returns if it failed
+		fmt.Fprintln(os.Stderr, runBatch(f))
+		os.Exit(1)
+	}
+	source, err := terminalSource(f.replay, f.record)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	syntaxProvider = treesitter.NewSyntaxProvider(buffer, getAttribute)
-	listener = input.New(os.Stdin)
-	core.SetCursors(0, 0, 0, 1)(editor)
 	renderer = advancedtui.NewTui()
-	renderer.SetSyntaxProvider(syntaxProvider)
+	if err := loadHistory(); err != nil {
+		startupErrors = append(startupErrors, "could not load the history: " + err.Error())
+	}
+	if f.config != "NONE" {
+		if err := runConfig(f.config); err != nil {
+			startupErrors = append(startupErrors, err.Error())
+		}
+	}
+	for _, file := range f.files {
+		if err := openFile(file); err != nil {
+			startupErrors = append(startupErrors, err.Error())
+		}
+	}
+	switchBuffer(0)
 
-	normalMode()
+	// Only the replay file can end or fail
+	err = runInput(source)
+	renderer.End()
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
 }
 
 func loadBuffer(filename string, buffer core.Buffer) {
+	// A missing file is a new one. Buffers always have a line, even if the
+	// file is empty.
+	lines, _ := readLines(filename)
+	if len(lines) == 0 {
+		lines = [][]rune{{}}
+	}
+	core.LoadLines(buffer, lines)
+}
+
+func readLines(filename string) ([][]rune, error) {
+	lines := [][]rune{}
 	contents, err := os.ReadFile(filename)
-	if err == nil {
-		lines := strings.Split(string(contents), "\n")
-		for i, line := range lines {
-			buffer.AddLine(i, []rune(line))
-		}
-	} else {
-		buffer.AddLine(0, []rune{})
+	if err != nil {
+		return lines, err
 	}
-	if buffer.GetLength() > 0 {
-		buffer.RemoveLine(buffer.GetLength()-1)
+	for _, line := range strings.Split(string(contents), "\n") {
+		lines = append(lines, []rune(line))
 	}
+	// The last newline doesn't start a line
+	return lines[:len(lines)-1], nil
 }
 
+// Returns the attribute of the longest kind of capture the name starts with
+// (see :highlight)
 func getAttribute(name string) hexes.Attribute {
-	if strings.HasPrefix(name, "type") {
-		return hexes.Join(hexes.NORMAL, hexes.YELLOW)
-	} else if strings.HasPrefix(name, "string") {
-		return hexes.Join(hexes.NORMAL, hexes.BLUE, hexes.ITALIC)
-	} else if strings.HasPrefix(name, "keyword") {
-		return hexes.Join(hexes.NORMAL, hexes.GREEN)
-	} else if strings.HasPrefix(name, "comment") {
-		return hexes.Join(hexes.NORMAL, hexes.BLACK, hexes.BOLD, hexes.ITALIC)
-	} else if strings.HasPrefix(name, "number") {
-		return hexes.Join(hexes.NORMAL, hexes.CYAN)
-	} else if strings.HasPrefix(name, "property") {
-		return hexes.Join(hexes.NORMAL, hexes.BLUE)
+	attribute, longest := hexes.NORMAL, -1
+	for kind, kindAttribute := range highlightAttributes {
+		if strings.HasPrefix(name, kind) && len(kind) > longest {
+			attribute, longest = kindAttribute, len(kind)
+		}
 	}
-	return hexes.NORMAL
+	return attribute
 }
 
@@ -4,32 +4,63 @@ import (
 	"unicode"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hhhhhhhhhn/hexes/input"
 	"github.com/hhhhhhhhhn/wr/core"
 )
 
-const eventsLength = 10
+const eventsLength = 64
 var events [eventsLength]*input.Event
+// Whether each event came from a :noremap (see noremapEvents)
+var eventsNoremap [eventsLength]bool
 var latestEvent = -1
 var eventIndex = -1
 
 func getEvent() *input.Event {
-	eventIndex++
-	for eventIndex > latestEvent {
+	return getEventBefore(nil)
+}
+
+// Like getEvent, but returns nil if no event comes before the timeout
+func getEventBefore(timeout <-chan time.Time) *input.Event {
+	if eventIndex == latestEvent {
+		event := nextEvent(timeout)
+		if event == nil {
+			return nil
+		}
 		latestEvent++
-		events[latestEvent % eventsLength] = listener.GetEvent()
+		events[latestEvent % eventsLength] = event
+		eventsNoremap[latestEvent % eventsLength] = noremapEvents[event]
+		delete(noremapEvents, event)
 	}
+	eventIndex++
 	return events[eventIndex % eventsLength]
 }
 
+// Like getEvent, but skips the events that are not keys, like the ones of
+// the mouse
+func getKey() *input.Event {
+	event := getEvent()
+	for event.EventType != input.KeyPressed {
+		event = getEvent()
+	}
+	return event
+}
+
 func unGetEvent() {
 	eventIndex--
 }
 
+// The multiplier typed before the last action
+var count = 1
+
 func getMultiplier() int {
+	count = readMultiplier()
+	return count
+}
+
+func readMultiplier() int {
 	multiplier := 0
 	for {
 		event := getEvent()
@@ -54,242 +85,319 @@ func getMultiplier() int {
 	}
 }
 
+// Reads the name of a register, returning -1 for a key that is not one
 func getRegister() int {
-	event := getEvent()
-	for event.EventType != input.KeyPressed {
-		event = getEvent()
+	register := int(getKey().Chr - 'a')
+	if register < 0 || register >= core.RegisterCount {
+		return -1
 	}
-	if event.Chr - 'a' < 0 || event.Chr - 'a' > 30 {
-		return 0
+	return register
+}
+
+// What the keys of a mode do. The keys are written like in :map.
+type binding struct {
+	movement func(multiplier int) core.Movement // Used as the mode does, like moving the cursors in normal mode
+	action   func()
+	edit     core.Edit // Done by insert mode
+	leave    bool      // The mode is left after it
+}
+
+var normalKeys, visualKeys, newCursorKeys, insertKeys *core.Keymap[binding]
+
+func init() {
+	for keys, action := range windowActions {
+		baseActions["<C-x>" + keys] = action
 	}
-	return int(event.Chr - 'a')
+	normalKeys = keymapOf(movementBindings(normalMovements), actionBindings(baseActions), actionBindings(normalActions))
+	visualKeys = keymapOf(movementBindings(visualMovements), actionBindings(baseActions), map[string]binding{
+		"<Esc>": {action: func() { core.GoTo(core.Unselect)(editor) }, leave: true},
+		"d":     {action: func() { repeatable("delete", core.AsEdit(core.Delete), false) }},
+	})
+	newCursorKeys = keymapOf(movementBindings(visualMovements), actionBindings(baseActions), map[string]binding{
+		"<Esc>": {leave: true},
+	})
+	// The other keys are typed
+	insertKeys = keymapOf(map[string]binding{
+		"<Esc>": {leave: true},
+		"<BS>": {edit: func(editor *core.Editor) {
+			core.GoTo(core.Chars(-1))(editor)
+			core.AsEdit(core.Delete)(editor)
+		}},
+		"<CR>": {edit: core.AsEdit(core.SmartSplit)},
+		"<Tab>": {edit: func(editor *core.Editor) {
+			core.AsEdit(core.InsertTab(options.Bool(current().options, "expandtab")))(editor)
+		}},
+	})
 }
 
-func normalGetMovement() (movement core.Movement, ok bool) {
-	multiplier := getMultiplier()
-	event := getEvent()
-	for event.EventType != input.KeyPressed {
-		event = getEvent()
+func movementBindings(movements map[string]func(int) core.Movement) map[string]binding {
+	bindings := map[string]binding{}
+	for keys, movement := range movements {
+		bindings[keys] = binding{movement: movement}
 	}
-	switch event.Chr {
-	case 'l':
-		return core.Chars(multiplier), true
-	case 'h':
-		return core.Chars(-multiplier), true
-	case 'w':
-		return core.Words(multiplier), true
-	case 'b':
-		return core.Words(-multiplier), true
-	case 'L':
-		return core.Columns(multiplier), true
-	case 'H':
-		return core.Columns(-multiplier), true
-	case 'j', 'J':
-		return core.Rows(multiplier), true
-	case 'k', 'K':
-		return core.Rows(-multiplier), true
-	case 'n':
-		return core.Regex(editor.Global["Regex"].(*regexp.Regexp), multiplier), true
-	case 'N':
-		return core.Regex(editor.Global["Regex"].(*regexp.Regexp), -multiplier), true
-	case '0':
-		return core.StartOfLine, true
-	case '$':
-		return core.EndOfLine, true
-	default:
-		unGetEvent()
-		return nil, false
+	return bindings
+}
+
+func actionBindings(actions map[string]func()) map[string]binding {
+	bindings := map[string]binding{}
+	for keys, action := range actions {
+		bindings[keys] = binding{action: action}
 	}
+	return bindings
 }
 
-func visualGetMovement() (movement core.Movement, ok bool) {
-	multiplier := getMultiplier()
-	event := getEvent()
-	for event.EventType != input.KeyPressed {
-		event = getEvent()
+func backwards(movement func(int) core.Movement) func(int) core.Movement {
+	return func(multiplier int) core.Movement {
+		return movement(-multiplier)
 	}
-	switch event.Chr {
-	case 'L':
-		return core.Chars(multiplier), true
-	case 'H':
-		return core.Chars(-multiplier), true
-	case 'w':
-		return core.Words(multiplier), true
-	case 'b':
-		return core.Words(-multiplier), true
-	case 'l':
-		return core.Columns(multiplier), true
-	case 'h':
-		return core.Columns(-multiplier), true
-	case 'j', 'J':
-		return core.Rows(multiplier), true
-	case 'k', 'K':
-		return core.Rows(-multiplier), true
-	case 'n':
-		return core.Regex(editor.Global["Regex"].(*regexp.Regexp), multiplier), true
-	case 'N':
-		return core.Regex(editor.Global["Regex"].(*regexp.Regexp), -multiplier), true
-	case '0':
-		return core.StartOfLine, true
-	case '$':
-		return core.EndOfLine, true
-	default:
-		unGetEvent()
-		return nil, false
+}
+
+// For the movements that don't take a multiplier
+func once(movement core.Movement) func(int) core.Movement {
+	return func(int) core.Movement {
+		return movement
 	}
 }
 
-func baseActions(char rune) (ok bool) {
-	switch(char) {
-	case 'u':
-		editor.Undo()
-		return true
-	case 'U':
-		editor.MarkUndo()
-		return true
-	case 18: // <C-r>
-		editor.Redo()
-		return true
-	case 23: // <C-w>
-		err := core.SaveToFile(editor, editor.Global["Filename"].(string))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+func searchMovement(multiplier int) core.Movement {
+	return core.Regex(searchRegex, multiplier)
+}
+
+var normalMovements = map[string]func(int) core.Movement{
+	"l": core.Chars,
+	"h": backwards(core.Chars),
+	"w": core.Words,
+	"b": backwards(core.Words),
+	"L": core.Columns,
+	"H": backwards(core.Columns),
+	"j": core.Rows,
+	"J": core.Rows,
+	"k": backwards(core.Rows),
+	"K": backwards(core.Rows),
+	"n": searchMovement,
+	"N": backwards(searchMovement),
+	"0": once(core.StartOfLine),
+	"$": once(core.EndOfLine),
+}
+
+// In visual mode, h and l keep the column
+var visualMovements = map[string]func(int) core.Movement{
+	"L": core.Chars,
+	"H": backwards(core.Chars),
+	"w": core.Words,
+	"b": backwards(core.Words),
+	"l": core.Columns,
+	"h": backwards(core.Columns),
+	"j": core.Rows,
+	"J": core.Rows,
+	"k": backwards(core.Rows),
+	"K": backwards(core.Rows),
+	"n": searchMovement,
+	"N": backwards(searchMovement),
+	"0": once(core.StartOfLine),
+	"$": once(core.EndOfLine),
+}
+
+// Reads a movement of normal mode, for the actions that take one, and the
+// count typed before it
+func readMovement() (movement func(multiplier int) core.Movement, multiplier int, ok bool) {
+	for {
+		getMultiplier()
+		binding, read := readKeys("normal", normalKeys, count)
+		switch {
+		case read == mapped:
+			continue
+		case read == unmatched:
+			getEvent()
+		case binding.movement != nil:
+			return binding.movement, count, true
 		}
-		quit()
-		return true
-	case 12: // <C-l>
+		return nil, 0, false
+	}
+}
+
+// The actions of normal, visual and new cursor mode
+var baseActions = map[string]func(){
+	"u": func() {
+		if changeable() {
+			editor.Undo()
+		}
+	},
+	"U":     func() { editor.MarkUndo() },
+	"<C-r>": func() {
+		if changeable() {
+			editor.Redo()
+		}
+	},
+	"<C-w>": func() { runAndShow("wq") },
+	"<C-p>": func() { runAndShow("files") },
+	"<C-l>": func() {
 		// TODO: Re-do with message
 		//renderer.Refresh()
 		//out.Flush()
-		return true
-	case 'v':
-		visualMode()
-		return true
-	case 22: // <C-v>
-		newCursorMode()
-		return true
-	case 'M':
-		memoryProfile()
-		return true
-	case 'C':
-		toggleCpuProf()
-		return true
-	case 'i':
-		editor.MarkUndo()
-		insertMode()
-		return true
-	case 'I':
-		editor.MarkUndo()
-		core.GoTo(core.StartOfLine)(editor)
-		insertMode()
-		return true
-	case 'a':
-		editor.MarkUndo()
-		core.GoTo(core.Chars(1))(editor)
-		insertMode()
-		return true
-	case 'A':
-		editor.MarkUndo()
-		core.GoTo(core.EndOfLine)(editor)
-		insertMode()
-		return true
-	case 'o':
-		editor.MarkUndo()
-		core.GoTo(core.EndOfLine)(editor)
-		core.AsEdit(core.Insert([]rune{'\n'}))(editor)
-		insertMode()
-		return true
-	case 'O':
-		editor.MarkUndo()
-		core.GoTo(core.StartOfLine)(editor)
-		core.AsEdit(core.Insert([]rune{'\n'}))(editor)
-		core.GoTo(core.Rows(-1))(editor)
-		insertMode()
-		return true
-	case 'd':
-		if movement, ok := normalGetMovement(); ok {
-			editor.MarkUndo()
-			core.SelectUntil(movement)(editor)
-			core.AsEdit(core.Delete)(editor)
+	},
+	"v":     visualMode,
+	"<C-v>": newCursorMode,
+	"<C-d>": func() { core.SelectNextMatch(false)(editor) },
+	"<C-k>": func() { core.SelectNextMatch(true)(editor) },
+	"q":     toggleRecording,
+	"m": func() {
+		name := getKey().Chr
+		if len(editor.Cursors) > 0 {
+			editor.SetMark(name, editor.Cursors[len(editor.Cursors)-1].Start)
 		}
-	case 'c':
-		if movement, ok := normalGetMovement(); ok {
-			editor.MarkUndo()
-			core.SelectUntil(movement)(editor)
-			core.AsEdit(core.Delete)(editor)
-			insertMode()
+	},
+	"@": func() {
+		times := count
+		if getKey().Chr == '@' {
+			playMacro(lastMacro, times)
+		} else {
+			unGetEvent()
+			playMacro(getRegister(), times)
+		}
+	},
+	"M": memoryProfile,
+	"C": toggleCpuProf,
+	"i": func() { repeatable("insert", func(*core.Editor) {}, true) },
+	"I": func() { repeatable("insert", core.GoTo(core.StartOfLine), true) },
+	"a": func() { repeatable("insert", core.GoTo(core.Chars(1)), true) },
+	"A": func() { repeatable("insert", core.GoTo(core.EndOfLine), true) },
+	"o": func() {
+		repeatable("insert", func(editor *core.Editor) {
+			core.GoTo(core.EndOfLine)(editor)
+			core.AsEdit(core.Insert([]rune{'\n'}))(editor)
+		}, true)
+	},
+	"O": func() {
+		repeatable("insert", func(editor *core.Editor) {
+			core.GoTo(core.StartOfLine)(editor)
+			core.AsEdit(core.Insert([]rune{'\n'}))(editor)
+			core.GoTo(core.Rows(-1))(editor)
+		}, true)
+	},
+	"d": func() {
+		if movement, multiplier, ok := readMovement(); ok {
+			repeatableWithCount("delete", func(multiplier int) core.Edit {
+				return func(editor *core.Editor) {
+					core.SelectUntil(movement(multiplier))(editor)
+					core.AsEdit(core.Delete)(editor)
+				}
+			}, multiplier, false)
+		}
+	},
+	"c": func() {
+		if movement, multiplier, ok := readMovement(); ok {
+			repeatableWithCount("change", func(multiplier int) core.Edit {
+				return func(editor *core.Editor) {
+					core.SelectUntil(movement(multiplier))(editor)
+					core.AsEdit(core.Delete)(editor)
+				}
+			}, multiplier, true)
+		}
+	},
+	"s": func() { repeatable("change", core.AsEdit(core.Delete), true) },
+	"x": func() { repeatable("delete", core.AsEdit(core.Delete), false) },
+	"y": func() {
+		if register := getRegister(); register >= 0 {
+			core.AsEdit(core.Yank(register))(editor)
+		}
+	},
+	"p": func() {
+		if register := getRegister(); register >= 0 {
+			repeatable("paste", core.AsEdit(core.Paste(register)), false)
+		}
+	},
+	".": func() {
+		if lastChange != nil {
+			lastChange(count)
+		}
+	},
+	":": func() { commandMode("") },
+	"/": func() { commandMode("/") },
+}
+
+var normalActions = map[string]func(){
+	"<Esc>": func() {
+		core.OnlyMainCursor(editor)
+		core.GoTo(core.Unselect)(editor)
+	},
+	"gg": func() {
+		core.OnlyMainCursor(editor)
+		core.GoTo(core.Position(0, 0, 0, 1))(editor)
+	},
+	"g-": func() {
+		if changeable() {
+			editor.UndoChronological(-1)
+		}
+	},
+	"g+": func() {
+		if changeable() {
+			editor.UndoChronological(1)
+		}
+	},
+	"g[": func() {
+		if changeable() {
+			editor.SwitchBranch(-1)
+		}
+	},
+	"g]": func() {
+		if changeable() {
+			editor.SwitchBranch(1)
+		}
+	},
+	"G": func() {
+		length := editor.Buffer.GetLength()
+		if length == 0 {
+			return
 		}
-		break
-	case 's':
-		editor.MarkUndo()
-		core.AsEdit(core.Delete)(editor)
-		insertMode()
-		break
-	case 'x':
-		editor.MarkUndo()
-		core.AsEdit(core.Delete)(editor)
-		break
-	case 'y':
-		core.AsEdit(core.Yank(getRegister()))(editor)
-		break
-	case 'p':
-		core.AsEdit(core.Paste(getRegister()))(editor)
-		break
-	case ':':
-		commandMode("")
-	case '/':
-		commandMode("/")
+		core.OnlyMainCursor(editor)
+		core.GoTo(core.Position(length-1, 0, length-1, 1))(editor)
+	},
+}
+
+// Reads and does a binding of the mode, where move is what its movements
+// do. Returns whether the mode is left.
+func modeAction(mode string, keymap *core.Keymap[binding], move func(core.Movement) core.Edit) (leave bool) {
+	getMultiplier()
+	binding, read := readKeys(mode, keymap, count)
+	switch {
+	// The escapes after the input ends only leave the mode
+	case inputEnd != nil:
+		return true
+	case read == unmatched:
+		getEvent()
+	case binding.movement != nil:
+		move(binding.movement(count))(editor)
+	case binding.action != nil:
+		binding.action()
 	}
-	return false
+	return binding.leave
 }
 
 func normalMode() {
 	pushMode("normal")
 	defer popMode()
+	if len(startupErrors) > 0 {
+		statusText, statusOk = strings.Join(startupErrors, " | "), false
+		renderer.ChangeStatus(statusText, statusOk)
+	}
 	lastCursor := defaultCursor
-	for {
-		if len(editor.Cursors) == 0 {
-			cursorCopy := lastCursor
-			core.PushCursor(&cursorCopy)(editor)
-		} else if !core.IsOOB(editor, editor.Cursors[len(editor.Cursors)-1]) {
-			lastCursor = *editor.Cursors[len(editor.Cursors)-1]
-		}
-		renderer.RenderEditor(editor)
-
-		movement, ok := normalGetMovement()
-		if ok {
-			core.GoTo(movement)(editor)
-			continue
-		}
-
-		event := getEvent()
-		if event.EventType != input.KeyPressed {
-			continue
-		}
+	for inputEnd == nil {
+		normalAction(&lastCursor)
+	}
+}
 
-		switch event.Chr {
-		case input.ESCAPE:
-			core.OnlyMainCursor(editor)
-			core.GoTo(core.Unselect)(editor)
-			break
-		case 'g':
-			if getEvent().Chr == 'g' {
-				core.OnlyMainCursor(editor)
-				core.GoTo(core.Position(0, 0, 0, 1))(editor)
-			}
-			break
-		case 'G':
-			length := editor.Buffer.GetLength()
-			if length == 0 {
-				break
-			}
-			core.OnlyMainCursor(editor)
-			core.GoTo(core.Position(length-1, 0, length-1, 1))(editor)
-		default:
-			baseActions(event.Chr)
-			break
-		}
+// Reads and does a single action in normal mode. The last cursor is restored
+// if all of them are removed.
+func normalAction(lastCursor *core.Cursor) {
+	if len(editor.Cursors) == 0 {
+		cursorCopy := *lastCursor
+		core.PushCursor(&cursorCopy)(editor)
+	} else if !core.IsOOB(editor, editor.Cursors[len(editor.Cursors)-1]) {
+		*lastCursor = *editor.Cursors[len(editor.Cursors)-1]
 	}
+	render()
+	modeAction("normal", normalKeys, core.GoTo)
 }
 
 func visualMode() {
@@ -299,30 +407,9 @@ func visualMode() {
 		for len(editor.Cursors) == 0 {
 			core.SetCursors(0, 0, 0, 1)(editor)
 		}
-		renderer.RenderEditor(editor)
-
-		movement, ok := visualGetMovement()
-		if ok {
-			core.ExpandSelection(movement)(editor)
-			continue
-		}
-
-		event := getEvent()
-		if event.EventType != input.KeyPressed {
-			continue
-		}
-
-		switch(event.Chr) {
-		case input.ESCAPE:
-			core.GoTo(core.Unselect)(editor)
+		render()
+		if modeAction("visual", visualKeys, core.ExpandSelection) {
 			return
-		case 'd':
-			editor.MarkUndo()
-			core.AsEdit(core.Delete)(editor)
-			break
-		default:
-			baseActions(event.Chr)
-			break
 		}
 	}
 }
@@ -334,35 +421,63 @@ func newCursorMode() {
 		for len(editor.Cursors) == 0 {
 			core.SetCursors(0, 0, 0, 1)(editor)
 		}
-		renderer.RenderEditor(editor)
-
-		movement, ok := visualGetMovement()
-		if ok {
-			core.PushCursorFromLast(movement)(editor)
-			continue
+		render()
+		if modeAction("new cursor", newCursorKeys, core.PushCursorFromLast) {
+			return
 		}
+	}
+}
 
-		event := getEvent()
-		if event.EventType != input.KeyPressed {
-			continue
-		}
+// The last change, repeated with ".", which gets the count typed before it
+var lastChange func(count int)
 
-		switch(event.Chr) {
-		case input.ESCAPE:
+// Does the change as a single undo step, and saves it as the last change. If
+// insert is true, insert mode is entered after it, and the typed text is
+// part of the change. A count before "." repeats it that many times.
+func repeatable(label string, change core.Edit, insert bool) {
+	repeatableWithCount(label, func(int) core.Edit { return change }, 0, insert)
+}
+
+// Like repeatable, for changes that take a count, like the one of the
+// movement of "d", which a count before "." replaces. A count of 0 is for
+// the ones that don't.
+func repeatableWithCount(label string, change func(count int) core.Edit, count int, insert bool) {
+	if !changeable() {
+		return
+	}
+	editor.MarkLabeledUndo(label)
+	change(count)(editor)
+	var edits []core.Edit
+	if insert {
+		edits = insertMode()
+	}
+	lastChange = func(newCount int) {
+		if !changeable() {
 			return
-		default:
-			baseActions(event.Chr)
-			break
+		}
+		times := 1
+		if newCount > 1 && count == 0 {
+			times = newCount
+		} else if newCount > 1 {
+			count = newCount
+		}
+		editor.MarkLabeledUndo(label)
+		for i := 0; i < times; i++ {
+			change(count)(editor)
+			for _, edit := range edits {
+				edit(editor)
+			}
 		}
 	}
 }
 
+// Returns the edits done, which are applied to every cursor
 // FIXME: Doesn't always match entered text
-func insertMode() {
+func insertMode() []core.Edit {
 	pushMode("insert")
 	defer popMode()
 
-	editor.MarkUndo()
+	checkpoint := editor.Checkpoint()
 	for len(editor.Cursors) > 50 {
 		core.RemoveCursor(editor.Cursors[0])(editor)
 	}
@@ -370,31 +485,29 @@ func insertMode() {
 	do := func(e core.Edit) {edits = append(edits, e); e(editor)}
 
 	for {
-		renderer.RenderEditor(editor)
-		event := getEvent()
-		if event.EventType != input.KeyPressed {
+		render()
+		binding, read := readKeys("insert", insertKeys, 1)
+		switch {
+		case read == mapped:
 			continue
-		}
-		switch(event.Chr) {
-		case input.ESCAPE:
-			editor.Undo()
+		case binding.leave:
+			editor.Rollback(checkpoint)
 			for _, edit := range edits {
 				edit(editor)
 			}
-			return
-		case input.BACKSPACE:
-			do(core.GoTo(core.Chars(-1)))
-			do(core.AsEdit(core.Delete))
-			break
-		case '\n':
-			do(core.AsEdit(core.SmartSplit))
+			return edits
+		case binding.edit != nil:
+			do(binding.edit)
 		default:
-			if unicode.IsGraphic(event.Chr) || event.Chr == '\t' {
+			event := getEvent()
+			if event.EventType != input.KeyPressed {
+				continue
+			}
+			if unicode.IsGraphic(event.Chr) {
 				do(core.AsEdit(core.Insert([]rune{event.Chr})))
 			} else {
 				do(core.AsEdit(core.Insert([]rune(fmt.Sprint(event.Chr)))))
 			}
-			break
 		}
 		if len(editor.Cursors) == 0 {
 			do(core.SetCursors(0, 0, 0, 1))
@@ -402,6 +515,18 @@ func insertMode() {
 	}
 }
 
+// Renders the editor, unless a macro (or :normal) is being played, so that
+// playing it is fast and the result is shown once it ends
+func render() {
+	if len(playback) == 0 {
+		renderer.RenderEditor(editor)
+	}
+}
+
+// Draws the screen again after a change done while waiting for input, like
+// the output of a job. Modes that draw something else change it.
+var redraw = render
+
 var modes = []string{}
 var statusText string
 var statusOk bool = true
@@ -417,7 +542,7 @@ func popMode() {
 }
 
 func updateStatusText() {
-	statusText = strings.Join(modes, " > ")
+	statusText = strings.Join(modes, " > ") + recordingStatus() + jobsStatus()
 	statusOk = true
 	renderer.ChangeStatus(statusText, statusOk)
 }
@@ -430,6 +555,13 @@ var defaultCursor = core.Cursor{
 }
 
 func quit() {
+	for _, j := range jobs {
+		j.cancel()
+	}
+	// The commands of batch mode are not typed by the user
+	if !batch {
+		saveHistory()
+	}
 	renderer.End()
 	os.Exit(0)
 }
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/hhhhhhhhhn/wr/treesitter"
+)
+
+// The options of :set, which changes the value of the current buffer or
+// window and the one new buffers start with, and :setlocal, which only
+// changes the first. New windows copy the ones of the window they split.
+var options = core.NewOptions()
+// The global options, and the buffer and window ones set with :set
+var globalOptions = core.OptionValues{}
+
+// Returns the values of the current buffer or window for the scope, or nil
+// if there is none, like while running the config file
+func localOptions(scope core.OptionScope) core.OptionValues {
+	switch {
+	case scope == core.BufferScope && len(openBuffers) > 0:
+		return current().options
+	case scope == core.WindowScope:
+		window := renderer.Focused()
+		if window.Options == nil {
+			window.Options = core.OptionValues{}
+		}
+		return window.Options
+	}
+	return nil
+}
+
+func setScopes(scope core.OptionScope) []core.OptionValues {
+	if local := localOptions(scope); local != nil {
+		return []core.OptionValues{local, globalOptions}
+	}
+	return []core.OptionValues{globalOptions}
+}
+
+func setLocalScopes(scope core.OptionScope) []core.OptionValues {
+	if local := localOptions(scope); local != nil {
+		return []core.OptionValues{local}
+	}
+	return []core.OptionValues{globalOptions}
+}
+
+// Returns the buffer options of a new buffer, and sets the tab size of its
+// editor
+func newBufferOptions(editor *core.Editor) core.OptionValues {
+	values := core.OptionValues{}
+	for name, value := range globalOptions {
+		if option, _ := options.Lookup(name); option.Scope == core.BufferScope {
+			values[name] = value
+		}
+	}
+	editor.Config.Tabsize = options.Int(values, "tabsize")
+	return values
+}
+
+func between(min, max int) func(any) error {
+	return func(value any) error {
+		if value.(int) < min || value.(int) > max {
+			return fmt.Errorf("it must be between %v and %v", min, max)
+		}
+		return nil
+	}
+}
+
+func init() {
+	options.Define(core.Option{
+		Name: "tabsize",
+		Type: core.IntOption,
+		Scope: core.BufferScope,
+		Default: editorConfig.Tabsize,
+		Validate: between(1, 32),
+		OnChange: func(value any) { setTabsize(value.(int)) },
+	})
+	options.Define(core.Option{
+		Name: "expandtab",
+		Type: core.BoolOption,
+		Scope: core.BufferScope,
+		Default: false,
+	})
+	options.Define(core.Option{
+		Name: "number",
+		Type: core.BoolOption,
+		Scope: core.WindowScope,
+		Default: false,
+		OnChange: func(value any) { renderer.Focused().Number = value.(bool) },
+	})
+	options.Define(core.Option{
+		Name: "scrolloff",
+		Type: core.IntOption,
+		Scope: core.WindowScope,
+		Default: 0,
+		Validate: between(0, 999),
+		OnChange: func(value any) { renderer.Focused().ScrollOff = value.(int) },
+	})
+	options.Define(core.Option{
+		Name: "wrap",
+		Type: core.BoolOption,
+		Scope: core.WindowScope,
+		Default: true,
+		OnChange: func(value any) { renderer.Focused().Wrap = value.(bool) },
+	})
+	// A regex can be set with :errorformat, which names it "custom"
+	options.Define(core.Option{
+		Name: "errorformat",
+		Type: core.StringOption,
+		Scope: core.GlobalScope,
+		Default: "auto",
+		Validate: func(value any) error {
+			if _, ok := getErrorFormats(value.(string)); !ok {
+				return fmt.Errorf("it must be one of %v", strings.Join(errorFormatNames(), ", "))
+			}
+			return nil
+		},
+	})
+	options.Define(core.Option{
+		Name: "defaultlanguage",
+		Type: core.EnumOption,
+		Scope: core.GlobalScope,
+		Default: "c",
+		Values: treesitter.LanguageNames(),
+	})
+	// Replaces <Leader> in the keys of :map
+	options.Define(core.Option{
+		Name: "leader",
+		Type: core.StringOption,
+		Scope: core.GlobalScope,
+		Default: "\\",
+		Validate: func(value any) error {
+			leader, err := core.ParseKeys(value.(string), keyNames, nil)
+			if err == nil && len(leader) == 0 {
+				err = errors.New("it can't be empty")
+			}
+			return err
+		},
+	})
+	// Whether the keys of a mapping wait at most timeoutlen milliseconds
+	// for the next one
+	options.Define(core.Option{
+		Name: "timeout",
+		Type: core.BoolOption,
+		Scope: core.GlobalScope,
+		Default: true,
+	})
+	options.Define(core.Option{
+		Name: "timeoutlen",
+		Type: core.IntOption,
+		Scope: core.GlobalScope,
+		Default: 1000,
+		Validate: between(0, 60000),
+	})
+	// Whether the undo history is kept in a file next to each file (see
+	// core.SaveUndoFile)
+	options.Define(core.Option{
+		Name: "undofile",
+		Type: core.BoolOption,
+		Scope: core.GlobalScope,
+		Default: true,
+	})
+	// Limits of the undo history of each buffer, 0 for none
+	options.Define(core.Option{
+		Name: "maxversions",
+		Type: core.IntOption,
+		Scope: core.GlobalScope,
+		Default: editorConfig.MaxVersions,
+		Validate: between(0, math.MaxInt32),
+		OnChange: func(value any) {
+			setHistoryLimit(func(config *core.EditorConfig) { config.MaxVersions = value.(int) })
+		},
+	})
+	options.Define(core.Option{
+		Name: "maxversionbytes",
+		Type: core.IntOption,
+		Scope: core.GlobalScope,
+		Default: editorConfig.MaxVersionBytes,
+		Validate: between(0, math.MaxInt32),
+		OnChange: func(value any) {
+			setHistoryLimit(func(config *core.EditorConfig) { config.MaxVersionBytes = value.(int) })
+		},
+	})
+	// In seconds
+	options.Define(core.Option{
+		Name: "maxversionage",
+		Type: core.IntOption,
+		Scope: core.GlobalScope,
+		Default: int(editorConfig.MaxVersionAge / time.Second),
+		Validate: between(0, math.MaxInt32),
+		OnChange: func(value any) {
+			setHistoryLimit(func(config *core.EditorConfig) {
+				config.MaxVersionAge = time.Duration(value.(int)) * time.Second
+			})
+		},
+	})
+	commands["set"] = func(args []string) (string, bool) {
+		return setOptions(args, setScopes)
+	}
+	commands["setlocal"] = func(args []string) (string, bool) {
+		return setOptions(args, setLocalScopes)
+	}
+}
+
+// The cursors of the other windows showing the buffer are kept in their
+// place too
+func setTabsize(size int) {
+	if editor == nil {
+		return
+	}
+	for _, window := range renderer.Windows() {
+		if window.Editor == editor && window != renderer.Focused() {
+			view := *editor
+			view.Cursors = window.Cursors
+			core.SetTabsize(size)(&view)
+		}
+	}
+	core.SetTabsize(size)(editor)
+}
+
+// Changes the config of new buffers and the open ones. The history is pruned
+// with the new limits after the next change.
+func setHistoryLimit(set func(config *core.EditorConfig)) {
+	set(&editorConfig)
+	for _, open := range openBuffers {
+		set(&open.editor.Config)
+	}
+}
+
+// Runs each argument (see core.Options.Set). Without them, it shows the
+// options that differ from their default.
+func setOptions(args []string, scopes func(core.OptionScope) []core.OptionValues) (string, bool) {
+	outputs := []string{}
+	if len(args) < 2 {
+		for _, name := range options.Names() {
+			option, _ := options.Lookup(name)
+			value := options.Get(scopes(option.Scope)[0], name)
+			if option.Format(value) != option.Format(option.Default) {
+				outputs = append(outputs, option.Format(value))
+			}
+		}
+		if len(outputs) == 0 {
+			return "all options have their default value", true
+		}
+		return strings.Join(outputs, " "), true
+	}
+	for _, argument := range args[1:] {
+		if argument == "" {
+			continue
+		}
+		output, err := options.Set(argument, scopes)
+		if err != nil {
+			return err.Error(), false
+		}
+		if output != "" {
+			outputs = append(outputs, output)
+		}
+	}
+	return strings.Join(outputs, " "), true
+}
+
+// Returns the names of the options, and the booleans with "no" before
+func optionNames() []string {
+	names := options.Names()
+	for _, name := range options.Names() {
+		if option, _ := options.Lookup(name); option.Type == core.BoolOption {
+			names = append(names, "no" + name)
+		}
+	}
+	return names
+}
+
+// Returns a copy, so that changing one doesn't change the other
+func copyOptionValues(values core.OptionValues) core.OptionValues {
+	result := core.OptionValues{}
+	for name, value := range values {
+		result[name] = value
+	}
+	return result
+}
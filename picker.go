@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"unicode"
+
+	"github.com/hhhhhhhhhn/hexes/input"
+	"github.com/hhhhhhhhhn/wr/advancedtui"
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/hhhhhhhhhn/wr/treesitter"
+)
+
+// Files bigger than this are not previewed by the file picker
+const maxPreviewSize = 1 << 20
+
+// Shows the part of a candidate around the row, or nothing if the editor is
+// nil
+type previewFunc func(index int) (editor *core.Editor, provider advancedtui.SyntaxProvider, row int)
+
+// Lets the user choose one of the candidates, filtering them with a fuzzy
+// query as they type. The preview can be nil.
+func pick(title string, candidates []string, preview previewFunc) (index int, ok bool) {
+	picker := &advancedtui.Picker{Title: title, Total: len(candidates)}
+	var results []core.FuzzyResult
+	filter := func() {
+		results = core.FuzzyFilter(string(picker.Query), candidates)
+		picker.Items = []advancedtui.PickerItem{}
+		for _, result := range results {
+			picker.Items = append(picker.Items, advancedtui.PickerItem{
+				Text:    candidates[result.Index],
+				Matched: result.Matched,
+			})
+		}
+		picker.Selected = 0
+	}
+	updatePreview := func() {
+		picker.Preview = nil
+		if preview == nil || len(results) == 0 {
+			return
+		}
+		previewEditor, provider, row := preview(results[picker.Selected].Index)
+		if previewEditor != nil {
+			picker.Preview = &advancedtui.Window{Editor: previewEditor, Provider: provider}
+			picker.PreviewRow = row
+		}
+	}
+	filter()
+	updatePreview()
+
+	previousRedraw := redraw
+	redraw = func() {
+		renderer.RenderPicker(editor, picker)
+	}
+	defer func() { redraw = previousRedraw }()
+
+	for {
+		if len(playback) == 0 {
+			redraw()
+		}
+		event := getEvent()
+		for event.EventType != input.KeyPressed {
+			event = getEvent()
+		}
+		switch event.Chr {
+		case input.ESCAPE:
+			return -1, false
+		case input.ENTER:
+			if len(results) == 0 {
+				return -1, false
+			}
+			return results[picker.Selected].Index, true
+		case input.KEY_UP, 16: // <C-p>
+			if picker.Selected > 0 {
+				picker.Selected--
+				updatePreview()
+			}
+		case input.KEY_DOWN, 14: // <C-n>
+			if picker.Selected < len(results) - 1 {
+				picker.Selected++
+				updatePreview()
+			}
+		case input.KEY_LEFT:
+			if picker.Cursor > 0 {
+				picker.Cursor--
+			}
+		case input.KEY_RIGHT:
+			if picker.Cursor < len(picker.Query) {
+				picker.Cursor++
+			}
+		case input.BACKSPACE:
+			if picker.Cursor > 0 {
+				picker.Query = append(picker.Query[:picker.Cursor-1], picker.Query[picker.Cursor:]...)
+				picker.Cursor--
+				filter()
+				updatePreview()
+			}
+		default:
+			if !unicode.IsPrint(event.Chr) {
+				break
+			}
+			query := append([]rune{}, picker.Query[:picker.Cursor]...)
+			query = append(query, event.Chr)
+			picker.Query = append(query, picker.Query[picker.Cursor:]...)
+			picker.Cursor++
+			filter()
+			updatePreview()
+		}
+	}
+}
+
+// Picks one of the files under the current directory, not ignored by
+// .gitignore files, and opens it
+func pickFile() (string, bool) {
+	files, err := core.ListFiles(".")
+	if err != nil {
+		return err.Error(), false
+	}
+	// Loaded files are kept while the picker is open
+	previews := map[string]*openBuffer{}
+	index, ok := pick("files", files, func(i int) (*core.Editor, advancedtui.SyntaxProvider, int) {
+		open, loaded := previews[files[i]]
+		if !loaded {
+			open = previewFile(files[i])
+			previews[files[i]] = open
+		}
+		if open == nil {
+			return nil, nil, 0
+		}
+		return open.editor, open.syntaxProvider, 0
+	})
+	if !ok {
+		return "", true
+	}
+	if err := openFile(files[index]); err != nil {
+		return err.Error(), false
+	}
+	return "opened " + current().filename, true
+}
+
+// Returns the open buffer of the file, or a new one without cursors or
+// history. Binary and big files are not previewed, so it returns nil.
+func previewFile(filename string) *openBuffer {
+	for _, open := range openBuffers {
+		if sameFile(open.filename, filename) {
+			return open
+		}
+	}
+	info, err := os.Stat(filename)
+	if err != nil || info.Size() > maxPreviewSize {
+		return nil
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil || core.IsBinary(contents) {
+		return nil
+	}
+
+	languageName := languageForFile(filename)
+	lang, _ := treesitter.GetLanguage(languageName)
+	buffer := treesitter.NewBuffer(*lang)
+	loadBuffer(filename, buffer)
+	editor := &core.Editor{Buffer: buffer, Config: editorConfig}
+	return &openBuffer{
+		filename: filename,
+		editor: editor,
+		buffer: buffer,
+		language: languageName,
+		syntaxProvider: treesitter.NewSyntaxProvider(buffer, getAttribute),
+		options: newBufferOptions(editor),
+	}
+}
+
+// Picks one of the open buffers, previewed around their main cursor, and
+// switches to it
+func pickBuffer() (string, bool) {
+	names := []string{}
+	for _, open := range openBuffers {
+		names = append(names, open.filename)
+	}
+	index, ok := pick("buffers", names, func(i int) (*core.Editor, advancedtui.SyntaxProvider, int) {
+		open := openBuffers[i]
+		row := 0
+		if len(open.editor.Cursors) > 0 {
+			row = open.editor.Cursors[len(open.editor.Cursors)-1].Start.Row
+		}
+		return open.editor, open.syntaxProvider, row
+	})
+	if !ok {
+		return "", true
+	}
+	switchBuffer(index)
+	return current().filename, true
+}
+
+// Picks one of the definitions in the current buffer, previewed around them,
+// and moves the cursor to its name
+func pickSymbol() (string, bool) {
+	if len(openBuffers) == 0 {
+		return errNoBuffer.Error(), false
+	}
+	symbols := current().buffer.Symbols()
+	names := []string{}
+	for _, symbol := range symbols {
+		names = append(names, fmt.Sprintf("%v (%v)", symbol.Name, symbol.Kind))
+	}
+	index, ok := pick("symbols", names, func(i int) (*core.Editor, advancedtui.SyntaxProvider, int) {
+		return editor, syntaxProvider, symbols[i].Row
+	})
+	if !ok {
+		return "", true
+	}
+	symbol := symbols[index]
+	column := core.ColumnSpan(editor, editor.Buffer.GetLine(symbol.Row)[:symbol.Index])
+	core.OnlyMainCursor(editor)
+	core.GoTo(core.Position(symbol.Row, column, symbol.Row, column + 1))(editor)
+	return names[index], true
+}
+
+// Picks one of the commands, and starts writing it in the command line
+func pickCommand() (string, bool) {
+	names := commandNames()
+	index, ok := pick("commands", names, nil)
+	if !ok {
+		return "", true
+	}
+	commandMode(names[index] + " ")
+	return statusText, statusOk
+}
+
+// Registered here, as the commands picker refers to the commands
+func init() {
+	commands["files"] = func([]string) (string, bool) {
+		return pickFile()
+	}
+	commands["buffers"] = func([]string) (string, bool) {
+		return pickBuffer()
+	}
+	commands["commands"] = func([]string) (string, bool) {
+		return pickCommand()
+	}
+	commands["symbols"] = func([]string) (string, bool) {
+		return pickSymbol()
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/hhhhhhhhhn/wr/treesitter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickSymbol(t *testing.T) {
+	startSession(t, "struct point { int x; };\n\nint add(int a, int b) {\n\treturn a + b;\n}\n\n#define SQUARE(x) ((x) * (x))\n")
+	assert.Equal(t, []treesitter.Symbol{
+		{Name: "point", Kind: "struct", Row: 0, Index: 7},
+		{Name: "add", Kind: "function", Row: 2, Index: 4},
+		{Name: "SQUARE", Kind: "macro", Row: 6, Index: 8},
+	}, current().buffer.Symbols())
+
+	typeKeys(t, ":symbols<CR>add<CR>")
+	assert.Equal(t, core.Location{Row: 2, Column: 4}, mainCursor())
+	text, ok := renderer.Status()
+	assert.Equal(t, "add (function)", text)
+	assert.True(t, ok)
+}
+
+func TestSymbolsOfLanguages(t *testing.T) {
+	sources := map[string]string{
+		"rust": "mod m {\n    struct S;\n    impl S {\n        fn é(&self) {}\n    }\n}\n",
+		"javascript": "class A {\n  m() {}\n}\nconst f = () => 1;\n",
+	}
+	expected := map[string][]treesitter.Symbol{
+		"rust": {
+			{Name: "m", Kind: "module", Row: 0, Index: 4},
+			{Name: "S", Kind: "struct", Row: 1, Index: 11},
+			{Name: "é", Kind: "function", Row: 3, Index: 11},
+		},
+		"javascript": {
+			{Name: "A", Kind: "class", Row: 0, Index: 6},
+			{Name: "m", Kind: "method", Row: 1, Index: 2},
+			{Name: "f", Kind: "function", Row: 3, Index: 6},
+		},
+	}
+	for language, source := range sources {
+		lang, err := treesitter.GetLanguage(language)
+		assert.Nil(t, err)
+		buffer := treesitter.NewBuffer(*lang)
+		for i, line := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+			buffer.AddLine(i, []rune(line))
+		}
+		assert.Equal(t, expected[language], buffer.Symbols(), language)
+	}
+}
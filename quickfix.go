@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// The entries of the last :make, :grep, :search or :cfile, gone through
+// with :cn and :cp
+var quickfix []core.QuickfixEntry
+var quickfixIndex = -1
+
+func setQuickfix(entries []core.QuickfixEntry) {
+	quickfix = entries
+	quickfixIndex = -1
+}
+
+// The regex set with :errorformat, nil until then
+var customErrorFormat *core.ErrorFormat
+
+// Like core.GetErrorFormats, but "custom" is the format of :errorformat
+func getErrorFormats(name string) ([]core.ErrorFormat, bool) {
+	if name == "custom" && customErrorFormat != nil {
+		return []core.ErrorFormat{*customErrorFormat}, true
+	}
+	return core.GetErrorFormats(name)
+}
+
+// Like core.ErrorFormatNames, with "custom" at the end once it is set
+func errorFormatNames() []string {
+	names := core.ErrorFormatNames()
+	if customErrorFormat != nil {
+		names = append(names, "custom")
+	}
+	return names
+}
+
+// Runs the command in the background, filling the quickfix list with its
+// output when it ends, and jumping to the first entry if there is any
+func quickfixJob(command string, formatName string) (string, bool) {
+	formats, ok := getErrorFormats(formatName)
+	if !ok {
+		return "unknown error format: " + formatName, false
+	}
+	return startJob(command, func(j *job) {
+		setQuickfix(core.ParseErrors(j.outputLines(), formats))
+		if len(quickfix) == 0 {
+			statusText += ", no entries"
+			return
+		}
+		statusText, statusOk = jumpToEntry(0)
+	})
+}
+
+// Runs make with the arguments
+func makeCommand(args []string) (string, bool) {
+	return quickfixJob(strings.Join(append([]string{"make"}, args[1:]...), " "), errorFormat())
+}
+
+// Runs grep -n on the arguments, which are the pattern and the files, or the
+// current directory if there are none
+func grepCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "please provide a pattern", false
+	}
+	if len(args) == 2 {
+		args = append(args, ".")
+	}
+	return quickfixJob("grep -rnI " + strings.Join(args[1:], " "), "grep")
+}
+
+// Searches the regex in the arguments in the files of the working directory
+// (see core.SearchFiles)
+func searchCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "please provide a regex", false
+	}
+	regex, err := regexp.Compile(strings.Join(args[1:], " "))
+	if err != nil {
+		return err.Error(), false
+	}
+	entries, err := core.SearchFiles(".", regex, runtime.NumCPU())
+	if err != nil {
+		return err.Error(), false
+	}
+	setQuickfix(entries)
+	if len(quickfix) == 0 {
+		return "pattern not found: " + regex.String(), false
+	}
+	return jumpToEntry(0)
+}
+
+// Fills the quickfix list with the contents of the file
+func cfileCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "please provide a file name", false
+	}
+	formats, ok := getErrorFormats(errorFormat())
+	if !ok {
+		return "unknown error format: " + errorFormat(), false
+	}
+	contents, err := os.ReadFile(strings.Join(args[1:], " "))
+	if err != nil {
+		return err.Error(), false
+	}
+	setQuickfix(core.ParseErrors(strings.Split(string(contents), "\n"), formats))
+	if len(quickfix) == 0 {
+		return "no entries", false
+	}
+	return jumpToEntry(0)
+}
+
+// Opens the file of the entry, with the cursor in its location
+func jumpToEntry(index int) (string, bool) {
+	if len(quickfix) == 0 {
+		return "the quickfix list is empty", false
+	}
+	if index < 0 || index >= len(quickfix) {
+		return "no more entries", false
+	}
+	quickfixIndex = index
+	entry := quickfix[index]
+	openErr := openFile(entry.Filename)
+
+	row := entry.Row
+	if row >= editor.Buffer.GetLength() {
+		row = editor.Buffer.GetLength() - 1
+	}
+	column := 0
+	if row >= 0 {
+		line := editor.Buffer.GetLine(row)
+		characters := entry.Column
+		if characters > len(line) {
+			characters = len(line)
+		}
+		column = core.ColumnSpan(editor, line[:characters])
+	}
+	core.OnlyMainCursor(editor)
+	core.GoTo(core.Position(row, column, row, column + 1))(editor)
+	if openErr != nil {
+		return openErr.Error(), false
+	}
+	return fmt.Sprintf("(%v of %v) %v", index + 1, len(quickfix), entry.Message), true
+}
+
+// Shows or sets the format for :make and :cfile, which can be a regex with
+// the groups file, line, col and message
+func errorFormatCommand(args []string) (string, bool) {
+	if len(args) < 2 {
+		return fmt.Sprintf("%v (one of %v, or a regex)", errorFormat(),
+			strings.Join(errorFormatNames(), ", ")), true
+	}
+	name := strings.Join(args[1:], " ")
+	if _, ok := getErrorFormats(name); !ok {
+		regex, err := regexp.Compile(name)
+		if err != nil {
+			return err.Error(), false
+		}
+		customErrorFormat = &core.ErrorFormat{Entry: regex}
+		name = "custom"
+	}
+	if _, err := options.Set("errorformat=" + name, setScopes); err != nil {
+		return err.Error(), false
+	}
+	return "error format set to " + strings.Join(args[1:], " "), true
+}
+
+// The name of the format used by :make and :cfile (see getErrorFormats)
+func errorFormat() string {
+	return options.String(globalOptions, "errorformat")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuickfixJobJumps(t *testing.T) {
+	startSession(t, "")
+	filename := filepath.Join(t.TempDir(), "main.c")
+	assert.Nil(t, os.WriteFile(filename, []byte("int a;\nint b;\n"), 0644))
+	_, ok := quickfixJob("echo " + filename + ":2:5: error: b", "gcc")
+	assert.True(t, ok)
+	assert.NotNil(t, current().job)
+	waitForJobs()
+	assert.Equal(t, filename, current().filename)
+	assert.Equal(t, core.Location{Row: 1, Column: 4}, mainCursor())
+	assert.Equal(t, "(1 of 1) error: b", statusText)
+
+	// Without entries, the output is kept
+	quickfixJob("echo nothing", "gcc")
+	waitForJobs()
+	assert.NotNil(t, current().job)
+	assert.Equal(t, current().job.status() + ", no entries", statusText)
+}
+
+func TestCustomErrorFormat(t *testing.T) {
+	startSession(t, "")
+	defer func() { customErrorFormat = nil }()
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "main.c")
+	assert.Nil(t, os.WriteFile(filename, []byte("int a;\nint b;\n"), 0644))
+	list := filepath.Join(dir, "errors")
+	assert.Nil(t, os.WriteFile(list, []byte("at " + filename + " line 2: wrong\n"), 0644))
+
+	output, ok := runCommand(`errorformat ^at (?P<file>\S+) line (?P<line>\d+): (?P<message>.*)$`)
+	assert.True(t, ok, output)
+	assert.Equal(t, "custom", errorFormat())
+	assert.NotContains(t, core.ErrorFormatNames(), "custom")
+	assert.Contains(t, errorFormatNames(), "custom")
+
+	output, ok = runCommand("cfile " + list)
+	assert.True(t, ok, output)
+	assert.Equal(t, "(1 of 1) wrong", output)
+	assert.Equal(t, filename, current().filename)
+	assert.Equal(t, 1, mainCursor().Row)
+}
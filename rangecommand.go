@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// Commands that take a range of lines (see core.ParseRange). The range is nil
+// if none was given, which for most of them means the current line.
+var rangeCommands = map[string] func(rangee *core.LineRange, args []string) (output string, ok bool) {
+	"s": func(rangee *core.LineRange, args []string) (string, bool) {
+		substitution, err := core.ParseSubstitution(strings.Join(args, " ")[1:])
+		if err != nil {
+			return err.Error(), false
+		}
+		if rangee == nil {
+			return substitute(substitution, substitution.CursorMatches(editor))
+		}
+		return substitute(substitution, substitution.LineMatches(editor, rangee.Start, rangee.End + 1))
+	},
+	"!": func(rangee *core.LineRange, args []string) (string, bool) {
+		shellCommand := strings.Join(args, " ")[1:]
+		// :!!command runs in the background
+		if strings.HasPrefix(shellCommand, "!") {
+			if rangee != nil {
+				return `command "!!" doesn't take a range`, false
+			}
+			return startJob(shellCommand[1:], nil)
+		}
+		if strings.TrimSpace(shellCommand) == "" {
+			return "please provide a command", false
+		}
+		if rangee == nil {
+			rangee = &core.LineRange{Start: 0, End: editor.Buffer.GetLength() - 1}
+		}
+		return filterLines(*rangee, shellCommand)
+	},
+	"d": func(rangee *core.LineRange, args []string) (string, bool) {
+		lines := currentLine(rangee)
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.MarkLabeledUndo("delete")
+		if len(args) > 1 {
+			core.YankLines(lines, registerArgument(args))(editor)
+		}
+		core.DeleteLines(lines)(editor)
+		return "", true
+	},
+	"y": func(rangee *core.LineRange, args []string) (string, bool) {
+		core.YankLines(currentLine(rangee), registerArgument(args))(editor)
+		return "", true
+	},
+	"m": func(rangee *core.LineRange, args []string) (string, bool) {
+		to, err := targetRow(args)
+		if err != nil {
+			return err.Error(), false
+		}
+		lines := currentLine(rangee)
+		if to >= lines.Start && to < lines.End {
+			return "can't move lines into themselves", false
+		}
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.MarkLabeledUndo("move")
+		core.MoveLines(lines, to)(editor)
+		return "", true
+	},
+	"t": func(rangee *core.LineRange, args []string) (string, bool) {
+		to, err := targetRow(args)
+		if err != nil {
+			return err.Error(), false
+		}
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.MarkLabeledUndo("copy")
+		core.CopyLinesTo(currentLine(rangee), to)(editor)
+		return "", true
+	},
+	"j": func(rangee *core.LineRange, args []string) (string, bool) {
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.MarkLabeledUndo("join")
+		core.JoinLines(currentLine(rangee))(editor)
+		return "", true
+	},
+	">": func(rangee *core.LineRange, args []string) (string, bool) {
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.MarkLabeledUndo("indent")
+		core.IndentLines(currentLine(rangee), strings.Count(args[0], ">"), options.Bool(current().options, "expandtab"))(editor)
+		return "", true
+	},
+	"<": func(rangee *core.LineRange, args []string) (string, bool) {
+		if err := checkChangeable(); err != nil {
+			return err.Error(), false
+		}
+		editor.MarkLabeledUndo("indent")
+		core.IndentLines(currentLine(rangee), -strings.Count(args[0], "<"), options.Bool(current().options, "expandtab"))(editor)
+		return "", true
+	},
+}
+
+// Added here to avoid an initialization cycle, as they run other commands
+func init() {
+	rangeCommands["normal"] = func(rangee *core.LineRange, args []string) (string, bool) {
+		if len(args) < 2 {
+			return "please provide the keys", false
+		}
+		return normalLines(currentLine(rangee), strings.Join(args[1:], " "))
+	}
+	rangeCommands["g"] = globalCommand
+	rangeCommands["v"] = globalCommand
+}
+
+func currentLine(rangee *core.LineRange) core.LineRange {
+	if rangee == nil {
+		row := core.CurrentRow(editor)
+		return core.LineRange{Start: row, End: row}
+	}
+	return *rangee
+}
+
+// The register in the second argument, like "a" in ":y a"
+func registerArgument(args []string) int {
+	if len(args) < 2 || len(args[1]) == 0 || args[1][0] < 'a' || args[1][0] > 'z' {
+		return 0
+	}
+	return int(args[1][0] - 'a')
+}
+
+// The address after the name of the command, like "0" in ":m0" or ":m 0"
+func targetRow(args []string) (int, error) {
+	address := strings.TrimSpace(strings.Join(args, " ")[1:])
+	row, rest, ok, err := core.ParseAddress(editor, address, core.CurrentRow(editor))
+	if err != nil {
+		return 0, err
+	}
+	if !ok || rest != "" || row < -1 || row >= editor.Buffer.GetLength() {
+		return 0, core.ErrInvalidRange
+	}
+	return row, nil
+}
+
+// Replaces the lines with the output of the shell command, which gets them
+// as input. Only the lines that changed are replaced.
+func filterLines(lines core.LineRange, shellCommand string) (string, bool) {
+	if err := checkChangeable(); err != nil {
+		return err.Error(), false
+	}
+	input := ""
+	for row := lines.Start; row <= lines.End; row++ {
+		input += string(editor.Buffer.GetLine(row)) + "\n"
+	}
+	output, err := shellFilter(shellCommand, input)
+	if err != nil {
+		return err.Error(), false
+	}
+	newLines := [][]rune{}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		newLines = append(newLines, []rune(line))
+	}
+	editor.MarkLabeledUndo("filter")
+	core.ReplaceLines(lines, newLines)(editor)
+	return "", true
+}
+
+// Runs the shell command with the input, returning its output. Text not
+// ending in a newline gets one for the command, which is removed from the
+// output, as most commands expect lines. On failure, the error is the first
+// line of stderr, if any.
+func shellFilter(shellCommand string, input string) (string, error) {
+	addedNewline := !strings.HasSuffix(input, "\n")
+	if addedNewline {
+		input += "\n"
+	}
+	command := exec.Command("/bin/sh", "-c", shellCommand)
+	command.Stdin = strings.NewReader(input)
+	stderr := &strings.Builder{}
+	command.Stderr = stderr
+	stdout, err := command.Output()
+	if err != nil {
+		if message, _, _ := strings.Cut(stderr.String(), "\n"); message != "" {
+			return "", errors.New(message)
+		}
+		return "", err
+	}
+	output := string(stdout)
+	if addedNewline {
+		output = strings.TrimSuffix(output, "\n")
+	}
+	return output, nil
+}
+
+// Does the keys in normal mode at the start of each line, as a single undo
+// step
+func normalLines(lines core.LineRange, keys string) (string, bool) {
+	rows := []int{}
+	for row := lines.Start; row <= lines.End; row++ {
+		rows = append(rows, row)
+	}
+	return forEachRow(rows, "normal", func() (string, bool) {
+		runNormal(keys)
+		return "", true
+	})
+}
+
+var inGlobal bool
+
+// Runs :g/pattern/command, or its inverse :v (or :g!), in the lines of the
+// range, or the whole buffer
+func globalCommand(rangee *core.LineRange, args []string) (string, bool) {
+	if inGlobal {
+		return "global commands can't be nested", false
+	}
+	arguments := strings.Join(args, " ")
+	invert := arguments[0] == 'v'
+	arguments = arguments[1:]
+	if strings.HasPrefix(arguments, "!") {
+		invert = true
+		arguments = arguments[1:]
+	}
+	regex, command, err := core.ParseGlobal(arguments)
+	if err != nil {
+		return err.Error(), false
+	}
+	if command == "" {
+		return "please provide a command", false
+	}
+	if rangee == nil {
+		rangee = &core.LineRange{Start: 0, End: editor.Buffer.GetLength() - 1}
+	}
+	rows := core.MatchingRows(editor, regex, *rangee, invert)
+	if len(rows) == 0 {
+		return "pattern not found: " + regex.String(), false
+	}
+
+	inGlobal = true
+	defer func() { inGlobal = false }()
+	output, ok := forEachRow(rows, "global", func() (string, bool) {
+		return runCommand(command)
+	})
+	if !ok {
+		return output, false
+	}
+	return fmt.Sprintf("%v lines", len(rows)), true
+}
+
+// Does the action with the cursor in each row, as a single undo step, and
+// stops at the first error. The rows are marked before starting, so they
+// follow the lines the action adds, removes or moves, and the ones removed
+// are skipped.
+func forEachRow(rows []int, label string, action func() (string, bool)) (string, bool) {
+	editor.StartUndoGroup(label)
+	defer editor.EndUndoGroup()
+	// Keeps the registers
+	var cursor core.Cursor
+	if len(editor.Cursors) > 0 {
+		cursor = *editor.Cursors[len(editor.Cursors)-1]
+	}
+	marked := core.NewMarkedBuffer(editor.Buffer, rows)
+	markedEditor := editor
+	markedEditor.Buffer = marked
+	defer func() { markedEditor.Buffer = marked.Buffer }()
+	for {
+		row, ok := marked.Next()
+		if !ok {
+			return "", true
+		}
+		if row >= editor.Buffer.GetLength() {
+			continue
+		}
+		cursor.Range = core.Range{
+			Start: core.Location{Row: row, Column: 0},
+			End:   core.Location{Row: row, Column: 1},
+		}
+		cursorCopy := cursor
+		editor.Cursors = []*core.Cursor{&cursorCopy}
+		output, ok := action()
+		if !ok {
+			return output, false
+		}
+		if len(editor.Cursors) > 0 {
+			cursor.Registers = editor.Cursors[len(editor.Cursors)-1].Registers
+		}
+	}
+}
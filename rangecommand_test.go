@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalCommand(t *testing.T) {
+	tests := []struct {
+		text    string
+		command string
+		result  string
+	}{
+		{"foo1\nx\nfoo2\n", "g/foo/d", "x"},
+		{"foo1\nx\nfoo2\n", "v/foo/d", "foo1\nfoo2"},
+		{"foo1\nx\nfoo2\n", "g/foo/m0", "foo2\nfoo1\nx"},
+		{"foo1\nx\nfoo2\n", "g/foo/m$", "x\nfoo1\nfoo2"},
+		{"foo1\nx\nfoo2\n", "g/foo/t$", "foo1\nx\nfoo2\nfoo1\nfoo2"},
+		{"foo1\nx\nfoo2\n", "g/foo/t.", "foo1\nfoo1\nx\nfoo2\nfoo2"},
+		{"foo1\nfoo2\nx\n", "g/foo/.,+1d", "x"},
+		{"foo1\nx\nfoo2\n", "g/foo/normal Ay", "foo1y\nx\nfoo2y"},
+	}
+	for _, test := range tests {
+		startSession(t, test.text)
+		output, ok := runCommand(test.command)
+		assert.True(t, ok, test.command + ": " + output)
+		assert.Equal(t, test.result, bufferText(), test.command)
+
+		editor.Undo()
+		assert.Equal(t, test.text[:len(test.text)-1], bufferText(), test.command)
+	}
+}
+
+func TestIndentCommand(t *testing.T) {
+	startSession(t, "a\nb\n")
+	runCommand("%>")
+	assert.Equal(t, "\ta\n\tb", bufferText())
+	runCommand("<")
+	assert.Equal(t, "a\n\tb", bufferText())
+
+	// Like <Tab> in insert mode, expandtab uses spaces
+	runCommand("setlocal expandtab tabsize=2")
+	runCommand("%>>")
+	assert.Equal(t, "    a\n    \tb", bufferText())
+	typeKeys(t, "A<Tab>x<Esc>")
+	assert.Equal(t, "    a x\n    \tb", bufferText())
+}
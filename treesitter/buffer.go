@@ -14,8 +14,12 @@ type Buffer struct {
 	base              core.Buffer
 	lineBytes         *rope.Rope[int]
 	lineBytesVersions map[core.Version]*rope.Rope[int]
+	// Byte where each line starts, only valid for the first lines (see
+	// GetLineByteStart)
+	lineStarts        []int
 
 	query             *sitter.Query
+	symbolsQuery      *sitter.Query
 	queryCursor       *sitter.QueryCursor
 	parser            *sitter.Parser
 	tree              *sitter.Tree
@@ -25,10 +29,15 @@ type Buffer struct {
 
 func (b *Buffer) AddLine(index int, line []rune) {
 	lineBytes := len(string(line) + "\n")
-	lineStartByte := uint32(b.GetLineByteStart(index))
 
 	b.base.AddLine(index, line)
 	b.lineBytes = b.lineBytes.Insert(index, []int{lineBytes})
+	b.invalidateLineStarts(index)
+
+	if b.tree == nil {
+		return
+	}
+	lineStartByte := uint32(b.GetLineByteStart(index))
 
 	b.tree.Edit(sitter.EditInput{
 		StartIndex: lineStartByte,
@@ -53,10 +62,15 @@ func (b *Buffer) AddLine(index int, line []rune) {
 
 func (b *Buffer) RemoveLine(index int) {
 	oldLineBytes     := uint32(b.GetLineBytes(index))
-	oldLineByteStart := uint32(b.GetLineByteStart(index))
 
 	b.base.RemoveLine(index)
 	b.lineBytes = b.lineBytes.Remove(index, index+1)
+	b.invalidateLineStarts(index)
+
+	if b.tree == nil {
+		return
+	}
+	oldLineByteStart := uint32(b.GetLineByteStart(index))
 
 	b.tree.Edit(sitter.EditInput{
 		StartIndex: oldLineByteStart,
@@ -80,12 +94,17 @@ func (b *Buffer) RemoveLine(index int) {
 }
 
 func (b *Buffer) ChangeLine(index int, line []rune) {
-	lineByteStart := uint32(b.GetLineByteStart(index))
 	oldLineBytes  := uint32(b.GetLineBytes(index))
 	newLineBytes  := len(string(line) + "\n")
 
 	b.base.ChangeLine(index, line)
 	b.lineBytes = b.lineBytes.Replace(index, []int{newLineBytes})
+	b.invalidateLineStarts(index)
+
+	if b.tree == nil {
+		return
+	}
+	lineByteStart := uint32(b.GetLineByteStart(index))
 
 	b.tree.Edit(sitter.EditInput{
 		StartIndex: lineByteStart,
@@ -116,14 +135,30 @@ func (b *Buffer) GetLineBytes(index int) int {
 	return b.lineBytes.Slice(index, index+1)[0]
 }
 
-// TODO: Optimize
+// The starts are cached, and only the ones after an edited line are
+// calculated again, so editing from the top to the bottom of the buffer (like
+// loading it) takes linear time.
 func (b *Buffer) GetLineByteStart(index int) int {
-	lines := b.lineBytes.Slice(0, index)
-	accum := 0
-	for _, line := range lines {
-		accum += line
+	if len(b.lineStarts) == 0 {
+		b.lineStarts = append(b.lineStarts, 0)
+	}
+	if index < len(b.lineStarts) {
+		return b.lineStarts[index]
+	}
+	known := len(b.lineStarts) - 1
+	start := b.lineStarts[known]
+	for _, lineBytes := range b.lineBytes.Slice(known, index) {
+		start += lineBytes
+		b.lineStarts = append(b.lineStarts, start)
+	}
+	return start
+}
+
+// The start of the edited line stays the same, but not the ones after it
+func (b *Buffer) invalidateLineStarts(index int) {
+	if index + 1 < len(b.lineStarts) {
+		b.lineStarts = b.lineStarts[:index+1]
 	}
-	return accum
 }
 
 func (b *Buffer) GetLength() int {
@@ -138,8 +173,34 @@ func (b *Buffer) Backup(destination core.Version) {
 func (b *Buffer) Restore(source core.Version) {
 	b.base.Restore(source)
 	b.lineBytes = b.lineBytesVersions[source]
+	b.lineStarts = nil
 
-	b.tree = b.parser.ParseInput(nil, b.input)
+	// Parsed again when needed, so that many restores in a row (like the
+	// ones done by insert mode in :normal) don't parse the whole buffer each
+	b.tree = nil
+	b.treesitterIsValid = false
+}
+
+func (b *Buffer) GetVersion(version core.Version) [][]rune {
+	return b.base.GetVersion(version)
+}
+
+func (b *Buffer) SetVersion(version core.Version, lines [][]rune) {
+	b.base.SetVersion(version, lines)
+	lineBytes := make([]int, len(lines))
+	for i, line := range lines {
+		lineBytes[i] = len(string(line) + "\n")
+	}
+	b.lineBytesVersions[version] = rope.NewRope(lineBytes, rope.DefaultSettings)
+}
+
+func (b *Buffer) DeleteVersion(version core.Version) {
+	b.base.DeleteVersion(version)
+	delete(b.lineBytesVersions, version)
+}
+
+func (b *Buffer) IsVersion(version core.Version) bool {
+	return b.base.IsVersion(version)
 }
 
 func (b *Buffer) UpdateTreesitter() {
@@ -216,12 +277,20 @@ func (b *Buffer) GetCaptureName(c sitter.QueryCapture) string {
 }
 
 func (b *Buffer) String() string {
+	b.UpdateTreesitter()
 	return b.tree.RootNode().String()
 }
 
 func (b *Buffer) SetLanguage(language Language) error {
 	b.tree = nil
 	b.parser.SetLanguage(language.sitter)
+	// Compiled first, so the symbols are found even if the highlights fail
+	b.symbolsQuery = nil
+	symbolsQuery, err := sitter.NewQuery(language.symbols, language.sitter)
+	if err != nil {
+		return err
+	}
+	b.symbolsQuery = symbolsQuery
 	query, err := sitter.NewQuery(language.query, language.sitter)
 	if err != nil {
 		return err
@@ -3,6 +3,7 @@ package treesitter
 import (
 	sitter "github.com/smacker/go-tree-sitter"
 	"fmt"
+	"path/filepath"
 )
 
 import (
@@ -15,6 +16,7 @@ type Language struct {
 	name string
 	sitter *sitter.Language
 	query []byte
+	symbols []byte
 }
 
 func GetLanguage(name string) (*Language, error) {
@@ -22,26 +24,52 @@ func GetLanguage(name string) (*Language, error) {
 	if err != nil {
 		return nil, err
 	}
+	symbols, err := GetSymbolsQuery(name)
+	if err != nil {
+		return nil, err
+	}
 	switch name {
 	case "javascript":
 		return &Language{
 			name: "javascript",
 			sitter: javascript.GetLanguage(),
 			query: query,
+			symbols: symbols,
 		}, nil
 	case "c":
 		return &Language{
 			name: "c",
 			sitter: c.GetLanguage(),
 			query: query,
+			symbols: symbols,
 		}, nil
 	case "rust":
 		return &Language{
 			name: "rust",
 			sitter: rust.GetLanguage(),
 			query: query,
+			symbols: symbols,
 		}, nil
 	default:
 		return nil, fmt.Errorf("Unknown language: %s", name)
 	}
 }
+
+// The names accepted by GetLanguage
+func LanguageNames() []string {
+	return []string{"c", "javascript", "rust"}
+}
+
+var extensions = map[string]string{
+	".c": "c",
+	".h": "c",
+	".js": "javascript",
+	".mjs": "javascript",
+	".rs": "rust",
+}
+
+// Returns the name of the language of a file based on its extension, or an
+// empty string if it is not known
+func LanguageNameForFile(filename string) string {
+	return extensions[filepath.Ext(filename)]
+}
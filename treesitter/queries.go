@@ -9,3 +9,9 @@ func GetQuery(language string) ([]byte, error) {
 	filename := "queries/" + language + ".scm"
 	return f.ReadFile(filename)
 }
+
+// The query of the definitions in the language (see Buffer.Symbols)
+func GetSymbolsQuery(language string) ([]byte, error) {
+	filename := "queries/symbols/" + language + ".scm"
+	return f.ReadFile(filename)
+}
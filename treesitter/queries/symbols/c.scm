@@ -0,0 +1,26 @@
+; Based on the tags.scm of tree-sitter-c. Each match captures the @name of a
+; @definition, named after its kind.
+
+(function_declarator
+  declarator: (identifier) @name) @definition.function
+
+(preproc_function_def
+  name: (identifier) @name) @definition.macro
+
+(preproc_def
+  name: (identifier) @name) @definition.macro
+
+(struct_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.struct
+
+(union_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.union
+
+(enum_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.enum
+
+(type_definition
+  declarator: (type_identifier) @name) @definition.type
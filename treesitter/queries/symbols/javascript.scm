@@ -0,0 +1,18 @@
+; Based on the tags.scm of tree-sitter-javascript. Each match captures the
+; @name of a @definition, named after its kind.
+
+(function_declaration
+  name: (identifier) @name) @definition.function
+
+(generator_function_declaration
+  name: (identifier) @name) @definition.function
+
+(variable_declarator
+  name: (identifier) @name
+  value: [(function) (arrow_function)]) @definition.function
+
+(class_declaration
+  name: (identifier) @name) @definition.class
+
+(method_definition
+  name: (property_identifier) @name) @definition.method
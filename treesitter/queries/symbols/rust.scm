@@ -0,0 +1,26 @@
+; Based on the tags.scm of tree-sitter-rust. Each match captures the @name of
+; a @definition, named after its kind.
+
+(function_item
+  name: (identifier) @name) @definition.function
+
+(struct_item
+  name: (type_identifier) @name) @definition.struct
+
+(enum_item
+  name: (type_identifier) @name) @definition.enum
+
+(union_item
+  name: (type_identifier) @name) @definition.union
+
+(type_item
+  name: (type_identifier) @name) @definition.type
+
+(trait_item
+  name: (type_identifier) @name) @definition.trait
+
+(mod_item
+  name: (identifier) @name) @definition.module
+
+(macro_definition
+  name: (identifier) @name) @definition.macro
@@ -0,0 +1,66 @@
+package treesitter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// A definition in the buffer, like a function or a type
+type Symbol struct {
+	Name  string
+	Kind  string // Like "function", from the @definition capture
+	Row   int
+	Index int // Of the rune where the name starts in the row
+}
+
+// Returns the definitions in the buffer, in the order they appear. A name
+// matched by more than one definition, like a C function with its
+// declarator, is only returned once.
+func (b *Buffer) Symbols() []Symbol {
+	symbols := []Symbol{}
+	if b.symbolsQuery == nil {
+		return symbols
+	}
+	if b.tree == nil {
+		b.treesitterIsValid = false
+	}
+	b.UpdateTreesitter()
+
+	cursor := sitter.NewQueryCursor()
+	cursor.Exec(b.symbolsQuery, b.tree.RootNode())
+	seen := map[uint32]bool{} // The start bytes of the names
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		var name *sitter.Node
+		kind := ""
+		for _, capture := range match.Captures {
+			captureName := b.symbolsQuery.CaptureNameForId(capture.Index)
+			if captureName == "name" {
+				name = capture.Node
+			} else if strings.HasPrefix(captureName, "definition.") {
+				kind = strings.TrimPrefix(captureName, "definition.")
+			}
+		}
+		if name == nil || kind == "" || seen[name.StartByte()] {
+			continue
+		}
+		start, end := name.StartPoint(), name.EndPoint()
+		line := []byte(string(b.GetLine(int(start.Row))))
+		if end.Row != start.Row || int(end.Column) > len(line) {
+			continue
+		}
+		seen[name.StartByte()] = true
+		symbols = append(symbols, Symbol{
+			Name: string(line[start.Column:end.Column]),
+			Kind: kind,
+			Row: int(start.Row),
+			Index: utf8.RuneCount(line[:start.Column]),
+		})
+	}
+	return symbols
+}
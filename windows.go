@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/hhhhhhhhhn/wr/advancedtui"
+	"github.com/hhhhhhhhhn/wr/core"
+)
+
+// Moves the focus to another window. As windows showing the same buffer share
+// its editor, the cursors of each are swapped in and out of it.
+func focusWindow(window *advancedtui.Window) {
+	if window == nil {
+		return
+	}
+	keepCursors(renderer.Focused(), editor.Cursors)
+	renderer.Focus(window)
+	enterWindow(window)
+}
+
+// Keeps the cursors in the window while it is not focused, with the contents
+// of its editor
+func keepCursors(window *advancedtui.Window, cursors []*core.Cursor) {
+	discardCursors(window)
+	window.Cursors = core.CopyCursors(cursors)
+	window.Version, _ = window.Editor.Versions.Next()
+	window.Editor.Buffer.Backup(window.Version)
+}
+
+func discardCursors(window *advancedtui.Window) {
+	if window.Version != 0 {
+		window.Editor.Buffer.DeleteVersion(window.Version)
+		window.Version = 0
+	}
+}
+
+// Switches to the buffer of the window, with its cursors moved by the changes
+// done since they were kept
+func enterWindow(window *advancedtui.Window) {
+	for i, open := range openBuffers {
+		if open.editor == window.Editor {
+			switchBuffer(i)
+		}
+	}
+	if window.Version != 0 {
+		core.FollowChanges(editor, window.Cursors, editor.Buffer.GetVersion(window.Version))
+		discardCursors(window)
+	}
+	editor.Cursors = window.Cursors
+}
+
+// The new window shows the same buffer and is focused
+func splitWindow(vertical bool) {
+	focused := renderer.Focused()
+	keepCursors(focused, editor.Cursors)
+	window := &advancedtui.Window{
+		Editor: editor,
+		Provider: focused.Provider,
+		Title: focused.Title,
+		Options: copyOptionValues(focused.Options),
+		Number: focused.Number,
+		ScrollOff: focused.ScrollOff,
+		Wrap: focused.Wrap,
+	}
+	renderer.SplitWindow(window, vertical)
+}
+
+func closeWindow() (string, bool) {
+	closed := renderer.Focused()
+	if !renderer.CloseWindow() {
+		return "can't close the last window", false
+	}
+	// The cursors of the closed window are discarded
+	discardCursors(closed)
+	enterWindow(renderer.Focused())
+	return "", true
+}
+
+// The actions of the keys after <C-x>
+var windowActions = map[string]func(){
+	"s": func() { splitWindow(false) },
+	"v": func() { splitWindow(true) },
+	"h": func() { focusWindow(renderer.WindowInDirection(0, -1)) },
+	"j": func() { focusWindow(renderer.WindowInDirection(1, 0)) },
+	"k": func() { focusWindow(renderer.WindowInDirection(-1, 0)) },
+	"l": func() { focusWindow(renderer.WindowInDirection(0, 1)) },
+	"w": func() { focusWindow(renderer.NextWindow(1)) },
+	"W": func() { focusWindow(renderer.NextWindow(-1)) },
+	"c": closeWindowAction,
+	"q": closeWindowAction,
+	"+": func() { renderer.ResizeWindow(1, false) },
+	"-": func() { renderer.ResizeWindow(-1, false) },
+	">": func() { renderer.ResizeWindow(1, true) },
+	"<lt>": func() { renderer.ResizeWindow(-1, true) },
+	"=": func() { renderer.EqualizeWindows() },
+}
+
+func closeWindowAction() {
+	statusText, statusOk = closeWindow()
+	renderer.ChangeStatus(statusText, statusOk)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hhhhhhhhhn/wr/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowCursorsFollowChanges(t *testing.T) {
+	startSession(t, "a\nb\nc\nd\n")
+	typeKeys(t, "G<C-x>s:1,2d<CR>")
+	assert.Equal(t, "c\nd", bufferText())
+
+	// The cursor of the first window stays in "d"
+	typeKeys(t, "<C-x>w")
+	assert.Equal(t, core.Location{Row: 1, Column: 0}, mainCursor())
+
+	// And the one of the second is kept inside the buffer
+	typeKeys(t, ":%d<CR><C-x>w")
+	assert.Equal(t, core.Location{Row: 0, Column: 0}, mainCursor())
+	assert.Equal(t, 1, len(editor.Cursors))
+
+	typeKeys(t, "<C-x>c")
+	assert.Equal(t, 1, len(renderer.Windows()))
+	assert.Equal(t, 0, renderer.Focused().Version)
+}